@@ -0,0 +1,140 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/terraform/tfdiags"
+)
+
+// Severity is the severity of a diagnostic
+type Severity string
+
+const (
+	// SeverityError is the severity of a diagnostic that blocks the operation
+	SeverityError Severity = "error"
+	// SeverityWarning is the severity of a diagnostic about a potential problem
+	SeverityWarning Severity = "warning"
+)
+
+// SourcePos is a position in a code file. Line and Column start at 1, Byte
+// starts at 0.
+type SourcePos struct {
+	Line, Column, Byte int
+}
+
+// SourceRange is a range of code in a file. The Filename is the name of the
+// file in the Platform Code.
+type SourceRange struct {
+	Filename   string
+	Start, End SourcePos
+}
+
+func (r SourceRange) String() string {
+	return fmt.Sprintf("%s:%d,%d", r.Filename, r.Start.Line, r.Start.Column)
+}
+
+// Diagnostic is an error or warning found in the platform code, variables or
+// providers. Subject is nil if the diagnostic is not related to the code.
+type Diagnostic struct {
+	Severity Severity
+	Summary  string
+	Detail   string
+	Subject  *SourceRange
+}
+
+func (d Diagnostic) String() string {
+	var s string
+	if d.Subject != nil {
+		s = d.Subject.String() + ": "
+	}
+	s += d.Summary
+	if len(d.Detail) != 0 {
+		s += "; " + d.Detail
+	}
+	return s
+}
+
+// Diagnostics is a list of diagnostics
+type Diagnostics []Diagnostic
+
+// HasErrors returns true if any of the diagnostics is an error
+func (diags Diagnostics) HasErrors() bool {
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns an error with all the error diagnostics, or nil if there is no
+// error in the diagnostics
+func (diags Diagnostics) Err() error {
+	var errs []string
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			errs = append(errs, d.String())
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(errs, "\n"))
+}
+
+// newDiagnostics converts the Terraform diagnostics. The filenames in the
+// source ranges are made relative to the given code directory, so they match
+// the filenames in the Platform Code.
+func newDiagnostics(tfDiags tfdiags.Diagnostics, cfgPath string) Diagnostics {
+	diags := make(Diagnostics, 0, len(tfDiags))
+	for _, tfDiag := range tfDiags {
+		desc := tfDiag.Description()
+		d := Diagnostic{
+			Severity: SeverityError,
+			Summary:  desc.Summary,
+			Detail:   desc.Detail,
+		}
+		if tfDiag.Severity() == tfdiags.Warning {
+			d.Severity = SeverityWarning
+		}
+		if subject := tfDiag.Source().Subject; subject != nil {
+			d.Subject = &SourceRange{
+				Filename: codeFilename(subject.Filename, cfgPath),
+				Start:    SourcePos(subject.Start),
+				End:      SourcePos(subject.End),
+			}
+		}
+		diags = append(diags, d)
+	}
+	return diags
+}
+
+// codeFilename returns the filename relative to the code directory
+func codeFilename(filename, cfgPath string) string {
+	if len(cfgPath) == 0 {
+		return filename
+	}
+	rel, err := filepath.Rel(cfgPath, filename)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filename
+	}
+	return rel
+}
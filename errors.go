@@ -0,0 +1,221 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/terraform/configs"
+	"github.com/hashicorp/terraform/tfdiags"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var (
+	// ErrNoCode is returned when the platform has no code to apply, validate or
+	// export
+	ErrNoCode = errors.New("no code")
+	// ErrCodeDirNotFound is returned when the directory to export the code
+	// doesn't exist
+	ErrCodeDirNotFound = errors.New("the directory to save the code was not found")
+	// ErrNoPlan is returned when a nil plan is given to apply or write
+	ErrNoPlan = errors.New("no plan")
+	// ErrUnknownPlan is returned when the given plan was not created or read by
+	// the platform, or it was already applied or discarded
+	ErrUnknownPlan = errors.New("the plan was not created by this platform or it was already applied or discarded")
+	// ErrStalePlan is returned when the state changed after the plan was created
+	ErrStalePlan = errors.New("the plan is stale, the state was changed after the plan was created")
+	// ErrEmptyState is returned when there is no state or it's empty
+	ErrEmptyState = errors.New("no state found or empty state")
+	// ErrNoOutputs is returned when there are no output values in the state
+	ErrNoOutputs = errors.New("no output values in the state")
+	// ErrNotTainted is returned when untainting a resource instance that is not
+	// tainted
+	ErrNotTainted = errors.New("resource instance is not tainted")
+	// ErrProviderNotInState is returned when no resource in the state uses the
+	// provider to replace
+	ErrProviderNotInState = errors.New("no resource in the state uses the provider")
+	// ErrNotLegacyProvider is returned when the provider address is not a legacy
+	// provider address, i.e. created with addrs.NewLegacyProvider
+	ErrNotLegacyProvider = errors.New("the provider is not a legacy provider")
+	// ErrProviderNotFound is returned when the provider is not added to the
+	// platform
+	ErrProviderNotFound = errors.New("the provider is not added to the platform")
+	// ErrStateNotLocked is returned when unlocking a state that is not locked
+	ErrStateNotLocked = errors.New("the state is not locked")
+	// ErrDefaultWorkspace is returned when deleting the default workspace
+	ErrDefaultWorkspace = errors.New("the default workspace cannot be deleted")
+	// ErrWorkspacesNotSupported is returned when using a workspace other than
+	// the default with a state backend that doesn't support workspaces
+	ErrWorkspacesNotSupported = errors.New("the state backend only supports the default workspace")
+	// ErrNoStateBackend is returned when the operation requires a state backend
+	// and the state is not persisted
+	ErrNoStateBackend = errors.New("the state is not persisted to a state backend")
+	// ErrKeyNotFound is returned when the key to decrypt the state is not found
+	ErrKeyNotFound = errors.New("state encryption key not found")
+	// ErrStateEncrypted is returned when reading an encrypted state without the
+	// keys to decrypt it
+	ErrStateEncrypted = errors.New("the state is encrypted, use EncryptState with the keys to decrypt it")
+	// ErrHistoryNotSupported is returned when the state backend doesn't keep the
+	// state history
+	ErrHistoryNotSupported = errors.New("the state backend doesn't keep the state history")
+	// ErrSnapshotNotFound is returned when the requested state snapshot is not in
+	// the state history
+	ErrSnapshotNotFound = errors.New("state snapshot not found")
+	// ErrInvalidWorkspace is returned when the workspace name is not valid
+	ErrInvalidWorkspace = errors.New("invalid workspace name, it has to be safe to use in a URL path")
+	// ErrWorkspaceNotFound is returned when the workspace is not in the state
+	// backend
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+	// ErrWorkspaceExists is returned when creating a workspace that already
+	// exists
+	ErrWorkspaceExists = errors.New("workspace already exists")
+	// ErrCurrentWorkspace is returned when deleting the selected workspace
+	ErrCurrentWorkspace = errors.New("the selected workspace cannot be deleted")
+	// ErrWorkspaceNotEmpty is returned when deleting a workspace with resources
+	// without forcing it
+	ErrWorkspaceNotEmpty = errors.New("the workspace state is not empty")
+)
+
+// UndeclaredVariableError is returned when a variable bound to the platform is
+// not declared in the code
+type UndeclaredVariableError struct {
+	Name string
+}
+
+func (e *UndeclaredVariableError) Error() string {
+	return fmt.Sprintf("variable %q is not declared in the code", e.Name)
+}
+
+// VariableTypeError is returned when the value of a variable bound to the
+// platform cannot be converted to the type declared in the code
+type VariableTypeError struct {
+	Name string
+	Type cty.Type
+	Err  error
+}
+
+func (e *VariableTypeError) Error() string {
+	return fmt.Sprintf("invalid value for variable %q of type %s. %s", e.Name, typeexpr.TypeString(e.Type), tfdiags.FormatError(e.Err))
+}
+
+// Unwrap returns the conversion error
+func (e *VariableTypeError) Unwrap() error {
+	return e.Err
+}
+
+// OutputNotFoundError is returned when the requested output value is not in
+// the state
+type OutputNotFoundError struct {
+	Name string
+}
+
+func (e *OutputNotFoundError) Error() string {
+	return fmt.Sprintf("value of %q not found", e.Name)
+}
+
+// ResourceNotFoundError is returned when the resource instance is not in the
+// state
+type ResourceNotFoundError struct {
+	Addr string
+}
+
+func (e *ResourceNotFoundError) Error() string {
+	return fmt.Sprintf("resource %s not found in the state", e.Addr)
+}
+
+// DiagnosticsError is returned when Terraform reports errors loading the code,
+// validating, refreshing, planning or applying it
+type DiagnosticsError struct {
+	Diags   tfdiags.Diagnostics
+	codeDir string
+}
+
+// newDiagnosticsError returns a *DiagnosticsError with the diagnostics of the
+// given configuration
+func newDiagnosticsError(diags tfdiags.Diagnostics, cfg *configs.Config) *DiagnosticsError {
+	return &DiagnosticsError{Diags: diags, codeDir: codeDir(cfg)}
+}
+
+// codeDir returns the directory where the code of the given configuration was
+// loaded from
+func codeDir(cfg *configs.Config) string {
+	if cfg == nil || cfg.Module == nil {
+		return ""
+	}
+	return cfg.Module.SourceDir
+}
+
+func (e *DiagnosticsError) Error() string {
+	if err := e.Diagnostics().Err(); err != nil {
+		return err.Error()
+	}
+	return "unknown error"
+}
+
+// Diagnostics returns the diagnostics with the filenames in the Platform Code
+func (e *DiagnosticsError) Diagnostics() Diagnostics {
+	return newDiagnostics(e.Diags, e.codeDir)
+}
+
+// StateConflictError is returned when saving the platform state would overwrite
+// a stored state with a different lineage or a higher serial than the one read
+// or saved by the platform, i.e. a state modified by another process
+type StateConflictError struct {
+	Lineage       string
+	Serial        uint64
+	StoredLineage string
+	StoredSerial  uint64
+}
+
+func (e *StateConflictError) Error() string {
+	if e.Lineage != e.StoredLineage {
+		return fmt.Sprintf("cannot overwrite the stored state with lineage %q with a state with lineage %q", e.StoredLineage, e.Lineage)
+	}
+	return fmt.Sprintf("cannot overwrite the stored state with serial %d, the platform state is based on the serial %d", e.StoredSerial, e.Serial)
+}
+
+// StateLockedError is returned when the state of a workspace is locked by
+// someone else. Info is the information of the lock holder, if known.
+type StateLockedError struct {
+	Workspace string
+	Info      *LockInfo
+}
+
+func (e *StateLockedError) Error() string {
+	if e.Info == nil {
+		return fmt.Sprintf("the state of workspace %q is locked", e.Workspace)
+	}
+	return fmt.Sprintf("the state of workspace %q is locked. Lock Info:\n%s", e.Workspace, e.Info)
+}
+
+// CancelError is returned when the context given to an operation is cancelled
+// or its deadline exceeded before the operation completes.
+type CancelError struct {
+	Op  string
+	Err error
+}
+
+func (e *CancelError) Error() string {
+	return fmt.Sprintf("%s cancelled. %s", e.Op, e.Err)
+}
+
+// Unwrap returns the context error, so errors.Is(err, context.Canceled) works
+func (e *CancelError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,110 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	var (
+		undeclaredErr *UndeclaredVariableError
+		outputErr     *OutputNotFoundError
+		diagsErr      *DiagnosticsError
+		typeErr       *VariableTypeError
+	)
+
+	tests := []struct {
+		name   string
+		errFn  func() error
+		target interface{}
+		is     error
+	}{
+		{"no code to apply", func() error { return NewPlatform("").Apply(false) }, nil, ErrNoCode},
+		{"no code to export", func() error { return NewPlatform("").Export("") }, nil, ErrNoCode},
+		{"export directory not found", func() error { return NewPlatform(nullDataSource).Export("/not/found") }, nil, ErrCodeDirNotFound},
+		{"invalid variables to export", func() error {
+			tmpDir, err := ioutil.TempDir("", ".terranova_errors_test")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(tmpDir)
+			return NewPlatform(nullDataSource).Var("foo", make(chan int)).Export(tmpDir)
+		}, &typeErr, nil},
+		{"undeclared variable", func() error {
+			_, err := NewPlatform(nullDataSource).Var("foo", "bar").Plan(false)
+			return err
+		}, &undeclaredErr, nil},
+		{"empty state", func() error {
+			_, err := NewPlatform("").OutputValueAsString("foo")
+			return err
+		}, nil, ErrEmptyState},
+		{"output not found", func() error {
+			p := NewPlatform(nullDataSource)
+			if err := p.Apply(false); err != nil {
+				return err
+			}
+			_, err := p.OutputValueAsString("foo")
+			return err
+		}, &outputErr, nil},
+		{"invalid code", func() error {
+			_, err := NewPlatform("resource \"null_resource\" \"foo\" {\n  triggers = \n}\n").Plan(false)
+			return err
+		}, &diagsErr, nil},
+		{"plan failure", func() error {
+			_, err := NewPlatform("output \"foo\" {\n  value = null_resource.missing.id\n}\n").Plan(false)
+			return err
+		}, &diagsErr, nil},
+		{"no plan", func() error { return NewPlatform("").ApplyPlan(nil) }, nil, ErrNoPlan},
+		{"stale plan", func() error {
+			p := newPlatformForTest(testsPlatformsFields["test instance"])
+			plan, err := p.Plan(false)
+			if err != nil {
+				return err
+			}
+			if err := p.Apply(false); err != nil {
+				return err
+			}
+			return p.ApplyPlan(plan)
+		}, nil, ErrStalePlan},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.errFn()
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if tt.is != nil && !errors.Is(err, tt.is) {
+				t.Errorf("errors.Is(%v, %v) = false, want true", err, tt.is)
+			}
+			if tt.target != nil && !errors.As(err, tt.target) {
+				t.Errorf("errors.As(%v, %T) = false, want true", err, tt.target)
+			}
+		})
+	}
+
+	if diagsErr == nil {
+		t.Fatalf("expected a *DiagnosticsError")
+	}
+	diags := diagsErr.Diagnostics()
+	if len(diags) == 0 || diags[0].Subject == nil || diags[0].Subject.Filename != "main.tf" {
+		t.Errorf("DiagnosticsError.Diagnostics() = %v, want a diagnostic in main.tf", diags)
+	}
+}
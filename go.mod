@@ -3,7 +3,9 @@ module github.com/johandry/terranova
 go 1.14
 
 require (
+	github.com/hashicorp/hcl/v2 v2.3.0
 	github.com/hashicorp/terraform v0.12.20
 	github.com/terraform-providers/terraform-provider-null v1.0.1-0.20190430203517-8d3d85a60e20
 	github.com/zclconf/go-cty v1.2.1
+	github.com/zclconf/go-cty-yaml v1.0.1
 )
@@ -0,0 +1,109 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/configs"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// ImportTarget is an existing resource to import. Addr is the resource instance
+// address in the code, i.e. `aws_instance.server[0]`, and ID is the resource
+// specific ID used by the provider to find it.
+type ImportTarget struct {
+	Addr string
+	ID   string
+}
+
+// Import imports the existing resource with the given ID into the given
+// resource instance address. The resource has to be declared in the code.
+func (p *Platform) Import(addr, id string) error {
+	return p.ImportResources(ImportTarget{Addr: addr, ID: id})
+}
+
+// ImportResources imports all the given existing resources. If the import of a
+// resource fails, the previously imported resources remain in the state.
+func (p *Platform) ImportResources(targets ...ImportTarget) (err error) {
+	p.startMiddleware()
+	p.resetWarnings()
+
+	unlock, err := p.lockState(context.Background(), "import")
+	if err != nil {
+		return err
+	}
+	defer unlock(&err)
+
+	tfCtx, _, err := p.newContext(false, nil)
+	if err != nil {
+		return err
+	}
+
+	importTargets := make([]*terraform.ImportTarget, 0, len(targets))
+	for _, target := range targets {
+		it, err := importTarget(tfCtx.Config(), target)
+		if err != nil {
+			return err
+		}
+		importTargets = append(importTargets, it)
+	}
+
+	newState, diags := tfCtx.Import(&terraform.ImportOpts{
+		Targets: importTargets,
+		Config:  tfCtx.Config(),
+	})
+	p.addWarnings(diags, codeDir(tfCtx.Config()))
+
+	if err := p.updateState(newState); err != nil {
+		return err
+	}
+
+	if diags.HasErrors() {
+		return newDiagnosticsError(diags, tfCtx.Config())
+	}
+	return nil
+}
+
+// importTarget verifies the import target address is declared in the code and
+// returns the Terraform import target with the provider to import it
+func importTarget(cfg *configs.Config, target ImportTarget) (*terraform.ImportTarget, error) {
+	addr, err := parseResourceInstance(target.Addr)
+	if err != nil {
+		return nil, err
+	}
+	if addr.Resource.Resource.Mode != addrs.ManagedResourceMode {
+		return nil, fmt.Errorf("%s is not a managed resource", addr)
+	}
+
+	modCfg := cfg.DescendentForInstance(addr.Module)
+	if modCfg == nil {
+		return nil, fmt.Errorf("module %s is not declared in the code", addr.Module)
+	}
+	rc := modCfg.Module.ResourceByAddr(addr.Resource.Resource)
+	if rc == nil {
+		return nil, fmt.Errorf("resource %s is not declared in the code", addr.ContainingResource())
+	}
+
+	return &terraform.ImportTarget{
+		Addr:         addr,
+		ID:           target.ID,
+		ProviderAddr: rc.ProviderConfigAddr().Absolute(addr.Module),
+	}, nil
+}
@@ -0,0 +1,91 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hashicorp/terraform/providers"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestPlatform_ImportResources(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets []ImportTarget
+		want    map[string]string
+		wantErr bool
+	}{
+		{"one resource", []ImportTarget{{"test_instance.foo", "i-1"}}, map[string]string{"foo": "i-1"}, false},
+		{"many resources", []ImportTarget{{"test_instance.foo", "i-1"}, {"test_instance.bar", "i-2"}}, map[string]string{"foo": "i-1", "bar": "i-2"}, false},
+		{"not declared", []ImportTarget{{"test_instance.baz", "i-3"}}, map[string]string{}, true},
+		{"invalid address", []ImportTarget{{"test_instance", "i-3"}}, map[string]string{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := NewMockProvider(nil, "test", testSimpleSchema())
+			mock.ImportResourceStateFn = func(req providers.ImportResourceStateRequest) providers.ImportResourceStateResponse {
+				return providers.ImportResourceStateResponse{
+					ImportedResources: []providers.ImportedResource{
+						{
+							TypeName: req.TypeName,
+							State: cty.ObjectVal(map[string]cty.Value{
+								"id":  cty.StringVal(req.ID),
+								"ami": cty.StringVal("bar"),
+							}),
+						},
+					},
+				}
+			}
+			p := newPlatformForTest(platformFields{
+				Code: testInstancesCode("foo", "bar"),
+				Providers: map[string]providers.Factory{
+					"test": providers.FactoryFixed(mock),
+				},
+			})
+
+			var err error
+			if len(tt.targets) == 1 {
+				err = p.Import(tt.targets[0].Addr, tt.targets[0].ID)
+			} else {
+				err = p.ImportResources(tt.targets...)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Platform.ImportResources() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := len(p.State.RootModule().Resources); got != len(tt.want) {
+				t.Errorf("Platform.ImportResources() state has %d resources, want %d", got, len(tt.want))
+			}
+			for name, id := range tt.want {
+				ri := p.State.ResourceInstance(testInstanceAddr(name))
+				if ri == nil || ri.Current == nil {
+					t.Errorf("Platform.ImportResources() test_instance.%s not found in the state", name)
+					continue
+				}
+				var attrs map[string]interface{}
+				if err := json.Unmarshal(ri.Current.AttrsJSON, &attrs); err != nil {
+					t.Fatalf("failed to decode the attributes of test_instance.%s. %s", name, err)
+				}
+				if attrs["id"] != id {
+					t.Errorf("Platform.ImportResources() test_instance.%s id = %v, want %v", name, attrs["id"], id)
+				}
+			}
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package terranova
 
 import (
-	"fmt"
 	"strings"
 
 	"github.com/hashicorp/terraform/states"
@@ -11,17 +10,17 @@ import (
 // OutputValueAsString returns the value of the Terraform output parameter in the code
 func (p *Platform) OutputValueAsString(name string) (string, error) {
 	if p.State == nil || p.State.Empty() {
-		return "", fmt.Errorf("no state found or empty state")
+		return "", ErrEmptyState
 	}
 
 	// p.State.RootModule() shouldn't be null, there's no need to check it's nil
 	output := p.State.RootModule().OutputValues
 	if output == nil {
-		return "", fmt.Errorf("no output values in the state")
+		return "", ErrNoOutputs
 	}
 
 	if _, ok := output[name]; !ok {
-		return "", fmt.Errorf("value of %q not found", name)
+		return "", &OutputNotFoundError{Name: name}
 	}
 
 	return valueAsString(output[name])
@@ -0,0 +1,150 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/hashicorp/terraform/plans"
+	"github.com/hashicorp/terraform/plans/planfile"
+	"github.com/hashicorp/terraform/states/statefile"
+)
+
+// WritePlan takes a io.Writer as input to write the given plan, created with
+// Plan, in the Terraform plan file format. The plan file includes the
+// configuration, variables and state the plan was created against, so it can
+// be applied later with ApplyPlan after reading it with ReadPlan.
+func (p *Platform) WritePlan(w io.Writer, plan *plans.Plan) (*Platform, error) {
+	// The plan file is a zip file, it can only be created in a file
+	f, err := ioutil.TempFile("", "terranova_plan")
+	if err != nil {
+		return p, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := p.WritePlanToFile(f.Name(), plan); err != nil {
+		return p, err
+	}
+
+	_, err = io.Copy(w, f)
+	return p, err
+}
+
+// ReadPlan takes a io.Reader as input to read from it a Terraform plan file,
+// created with WritePlan. The returned plan can be applied with ApplyPlan.
+func (p *Platform) ReadPlan(r io.Reader) (*plans.Plan, error) {
+	// The plan file is a zip file, it can only be read from a file
+	f, err := ioutil.TempFile("", "terranova_plan")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return nil, err
+	}
+
+	return p.ReadPlanFromFile(f.Name())
+}
+
+// WritePlanToFile save the given plan to a Terraform plan file
+func (p *Platform) WritePlanToFile(filename string, plan *plans.Plan) (*Platform, error) {
+	if plan == nil {
+		return p, ErrNoPlan
+	}
+
+	p.mu.Lock()
+	prior, ok := p.planned[plan]
+	p.mu.Unlock()
+	if !ok {
+		return p, ErrUnknownPlan
+	}
+
+	sf := statefile.New(prior.state, prior.lineage, prior.serial)
+	return p, planfile.Create(filename, prior.snapshot, sf, plan)
+}
+
+// DiscardPlan releases the configuration and state kept to apply or write the
+// given plan, created with Plan or read with ReadPlan. The plan can't be
+// applied or written after it's discarded. Only the last plans are kept, so
+// it's not required to discard every plan not applied.
+func (p *Platform) DiscardPlan(plan *plans.Plan) *Platform {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.removePlanned(plan)
+	return p
+}
+
+// MaxPlans sets how many plans the platform keeps to be applied or written
+// later, by default 10. When a new plan is created or read the oldest one is
+// discarded, so a plan waiting a long time for approval should be saved with
+// WritePlan and loaded with ReadPlan to apply it. A number lower than 1 sets
+// the default.
+func (p *Platform) MaxPlans(n int) *Platform {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.maxPlans = n
+	p.discardOldestPlanned()
+	return p
+}
+
+// ReadPlanFromFile will load a plan from a Terraform plan file. The returned
+// plan can be applied with ApplyPlan.
+func (p *Platform) ReadPlanFromFile(filename string) (*plans.Plan, error) {
+	pf, err := planfile.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer pf.Close()
+
+	plan, err := pf.ReadPlan()
+	if err != nil {
+		return nil, err
+	}
+
+	sf, err := pf.ReadStateFile()
+	if err != nil {
+		return nil, err
+	}
+
+	snap, err := pf.ReadConfigSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	config, diags := pf.ReadConfig()
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to load the configuration from the plan. %w", newDiagnosticsError(diags, nil))
+	}
+
+	p.addPlanned(plan, &plannedState{
+		lineage:  sf.Lineage,
+		serial:   sf.Serial,
+		config:   config,
+		snapshot: snap,
+		state:    sf.State,
+	})
+
+	return plan, nil
+}
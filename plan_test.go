@@ -0,0 +1,207 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/plans"
+	"github.com/hashicorp/terraform/states/statefile"
+)
+
+func TestPlatform_WritePlan_ReadPlan(t *testing.T) {
+	tests := []struct {
+		name           string
+		platformFields platformFields
+		stateChanged   bool
+		wantErr        error
+	}{
+		{"same state", testsPlatformsFields["test instance"], false, nil},
+		{"state changed", testsPlatformsFields["test instance"], true, ErrStalePlan},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planner := newPlatformForTest(tt.platformFields)
+			plan, err := planner.Plan(false)
+			if err != nil {
+				t.Fatalf("Platform.Plan() error = %v", err)
+			}
+
+			var planFile bytes.Buffer
+			if _, err := planner.WritePlan(&planFile, plan); err != nil {
+				t.Fatalf("Platform.WritePlan() error = %v", err)
+			}
+
+			// The applier reads the state used by the planner, with the same lineage
+			// and, if the state changed, only a newer serial
+			var state bytes.Buffer
+			if _, err := planner.WriteState(&state); err != nil {
+				t.Fatalf("Platform.WriteState() error = %v", err)
+			}
+			sf, err := statefile.Read(&state)
+			if err != nil {
+				t.Fatalf("failed to read the planner state. %s", err)
+			}
+			if tt.stateChanged {
+				sf.Serial++
+			}
+			state.Reset()
+			if err := statefile.Write(sf, &state); err != nil {
+				t.Fatalf("failed to write the planner state. %s", err)
+			}
+			applier := newPlatformForTest(tt.platformFields)
+			if _, err := applier.ReadState(&state); err != nil {
+				t.Fatalf("Platform.ReadState() error = %v", err)
+			}
+			gotPlan, err := applier.ReadPlan(&planFile)
+			if err != nil {
+				t.Fatalf("Platform.ReadPlan() error = %v", err)
+			}
+			if got, want := len(gotPlan.Changes.Resources), len(plan.Changes.Resources); got != want {
+				t.Errorf("Platform.ReadPlan() plan has %d resource changes, want %d", got, want)
+			}
+
+			err = applier.ApplyPlan(gotPlan)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Platform.ApplyPlan() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if got := len(applier.State.RootModule().Resources); got != 1 {
+				t.Errorf("Platform.ApplyPlan() state has %d resources, want 1", got)
+			}
+		})
+	}
+
+	if _, err := NewPlatform("").WritePlan(&bytes.Buffer{}, &plans.Plan{}); err == nil {
+		t.Errorf("Platform.WritePlan() expected an error writing an unknown plan")
+	}
+	if _, err := NewPlatform("").ReadPlan(strings.NewReader("not a plan")); err == nil {
+		t.Errorf("Platform.ReadPlan() expected an error reading an invalid plan file")
+	}
+}
+
+func TestPlatform_WritePlanToFile_ReadPlanFromFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", ".terranova_plan_test")
+	if err != nil {
+		t.Fatalf("Failed to create temporal directory. %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	filename := filepath.Join(tmpDir, "test.tfplan")
+
+	p := newPlatformForTest(testsPlatformsFields["test instance"])
+	plan, err := p.Plan(false)
+	if err != nil {
+		t.Fatalf("Platform.Plan() error = %v", err)
+	}
+	if _, err := p.WritePlanToFile(filename, plan); err != nil {
+		t.Fatalf("Platform.WritePlanToFile() error = %v", err)
+	}
+
+	gotPlan, err := p.ReadPlanFromFile(filename)
+	if err != nil {
+		t.Fatalf("Platform.ReadPlanFromFile() error = %v", err)
+	}
+	if err := p.ApplyPlan(gotPlan); err != nil {
+		t.Errorf("Platform.ApplyPlan() error = %v", err)
+	}
+}
+
+func TestPlatform_Plan_discardOldest(t *testing.T) {
+	p := newPlatformForTest(testsPlatformsFields["test instance"])
+
+	var created []*plans.Plan
+	for i := 0; i <= defaultMaxPlanned; i++ {
+		plan, err := p.Plan(false)
+		if err != nil {
+			t.Fatalf("Platform.Plan() error = %v", err)
+		}
+		created = append(created, plan)
+	}
+	if len(p.planned) != defaultMaxPlanned || len(p.plannedOrder) != defaultMaxPlanned {
+		t.Errorf("Platform kept %d plans (%d ordered), want %d", len(p.planned), len(p.plannedOrder), defaultMaxPlanned)
+	}
+
+	var w bytes.Buffer
+	if _, err := p.WritePlan(&w, created[0]); !errors.Is(err, ErrUnknownPlan) {
+		t.Errorf("Platform.WritePlan() of the oldest plan error = %v, want %v", err, ErrUnknownPlan)
+	}
+	if _, err := p.WritePlan(&w, created[1]); err != nil {
+		t.Errorf("Platform.WritePlan() error = %v", err)
+	}
+
+	for _, plan := range created {
+		p.DiscardPlan(plan)
+	}
+	if len(p.planned) != 0 || len(p.plannedOrder) != 0 {
+		t.Errorf("Platform kept %d plans (%d ordered) after discarding them, want 0", len(p.planned), len(p.plannedOrder))
+	}
+}
+
+func TestPlatform_MaxPlans(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxPlans int
+		want     int
+	}{
+		{"default", 0, defaultMaxPlanned},
+		{"less plans", 2, 2},
+		{"more plans", defaultMaxPlanned + 5, defaultMaxPlanned + 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPlatformForTest(testsPlatformsFields["test instance"]).MaxPlans(tt.maxPlans)
+
+			var created []*plans.Plan
+			for i := 0; i < tt.want+1; i++ {
+				plan, err := p.Plan(false)
+				if err != nil {
+					t.Fatalf("Platform.Plan() error = %v", err)
+				}
+				created = append(created, plan)
+			}
+			if got := len(p.plannedOrder); got != tt.want {
+				t.Errorf("Platform kept %d plans, want %d", got, tt.want)
+			}
+			if err := p.ApplyPlan(created[0]); !errors.Is(err, ErrUnknownPlan) {
+				t.Errorf("Platform.ApplyPlan() of the oldest plan error = %v, want %v", err, ErrUnknownPlan)
+			}
+			if err := p.ApplyPlan(created[len(created)-1]); err != nil {
+				t.Errorf("Platform.ApplyPlan() of the last plan error = %v", err)
+			}
+		})
+	}
+
+	// Lowering the limit discards the oldest plans already kept
+	p := newPlatformForTest(testsPlatformsFields["test instance"])
+	for i := 0; i < 3; i++ {
+		if _, err := p.Plan(false); err != nil {
+			t.Fatalf("Platform.Plan() error = %v", err)
+		}
+	}
+	if got := len(p.MaxPlans(1).plannedOrder); got != 1 {
+		t.Errorf("Platform.MaxPlans() kept %d plans, want 1", got)
+	}
+}
@@ -18,12 +18,15 @@ package terranova
 
 import (
 	"sync"
+	"time"
 
 	"github.com/hashicorp/terraform/addrs"
 	"github.com/hashicorp/terraform/backend/local"
+	"github.com/hashicorp/terraform/plans"
 	"github.com/hashicorp/terraform/providers"
 	"github.com/hashicorp/terraform/provisioners"
 	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
 	"github.com/hashicorp/terraform/states/statemgr"
 	"github.com/hashicorp/terraform/terraform"
 	"github.com/johandry/terranova/logger"
@@ -32,17 +35,34 @@ import (
 
 // Platform is the platform to be managed by Terraform
 type Platform struct {
-	Code          map[string]string
-	Providers     map[addrs.Provider]providers.Factory
-	Provisioners  map[string]provisioners.Factory
-	Vars          map[string]interface{}
-	State         *State
-	Hooks         []terraform.Hook
-	LogMiddleware *logger.Middleware
-	stateMgr      statemgr.Writer
-	countHook     *local.CountHook
-	ExpectedStats *Stats
-	mu            sync.Mutex
+	Code             map[string]string
+	Providers        map[addrs.Provider]providers.Factory
+	Provisioners     map[string]provisioners.Factory
+	Vars             map[string]interface{}
+	varFiles         []varFile
+	varSources       []VariableSource
+	Targets          []string
+	ForceReplace     []string
+	State            *State
+	Hooks            []terraform.Hook
+	LogMiddleware    *logger.Middleware
+	backend          StateBackend
+	countHook        *local.CountHook
+	ExpectedStats    *Stats
+	mu               sync.Mutex
+	lineage          string
+	serial           uint64
+	storedSerial     uint64
+	lockTimeout      time.Duration
+	staleLockTimeout time.Duration
+	keys             KeyProvider
+	workspace        string
+	workspaceStates  map[string]*statefile.File
+	planned          map[*plans.Plan]*plannedState
+	plannedOrder     []*plans.Plan
+	maxPlans         int
+	warnings         Diagnostics
+	warningFn        func(Diagnostic)
 }
 
 // State is an alias for terraform.State
@@ -62,6 +82,8 @@ func NewPlatform(code string, hooks ...terraform.Hook) *Platform {
 	}
 
 	platform.State = states.NewState()
+	platform.lineage = statemgr.NewLineage()
+	platform.workspace = DefaultWorkspace
 
 	return platform
 }
@@ -117,6 +139,24 @@ func (p *Platform) Var(name string, value interface{}) *Platform {
 	return p
 }
 
+// Target limits the operations to the given resource or module addresses and
+// their dependencies, i.e. `aws_instance.server` or `module.db`. Call it without
+// addresses to remove the targets.
+func (p *Platform) Target(targets ...string) *Platform {
+	p.Targets = targets
+	return p
+}
+
+// Replace forces the replacement of the given resource instances, i.e.
+// `aws_instance.server[0]`, in every Plan or Apply until the changes are
+// applied successfully with Apply or ApplyPlan, then the replacements are
+// removed. Unlike Taint, the state is not modified until the changes are
+// applied. Call it without addresses to remove the replacements.
+func (p *Platform) Replace(instances ...string) *Platform {
+	p.ForceReplace = instances
+	return p
+}
+
 // SetMiddleware assigns the given log middleware into the Platform
 func (p *Platform) SetMiddleware(lm *logger.Middleware) *Platform {
 	p.LogMiddleware = lm
@@ -0,0 +1,229 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states"
+)
+
+// DriftStatus is the status of a resource instance after a refresh
+type DriftStatus int
+
+const (
+	// DriftUnchanged means the resource instance is the same as in the state
+	DriftUnchanged DriftStatus = iota
+	// DriftChanged means some attributes of the resource instance changed
+	DriftChanged
+	// DriftGone means the resource instance does not exists anymore
+	DriftGone
+)
+
+func (s DriftStatus) String() string {
+	switch s {
+	case DriftUnchanged:
+		return "unchanged"
+	case DriftChanged:
+		return "changed"
+	case DriftGone:
+		return "gone"
+	}
+	return fmt.Sprintf("DriftStatus(%d)", int(s))
+}
+
+// AttributeDrift is the value of a resource instance attribute before and
+// after the refresh
+type AttributeDrift struct {
+	Name   string
+	Before interface{}
+	After  interface{}
+}
+
+// ResourceDrift is the drift of a managed resource instance found by a refresh
+type ResourceDrift struct {
+	Addr       string
+	Status     DriftStatus
+	Attributes []AttributeDrift
+}
+
+// DriftReport is the result of a refresh, it contains the drift of every
+// managed resource instance in the state
+type DriftReport struct {
+	Resources []ResourceDrift
+}
+
+// HasDrift returns true if any resource instance changed or is gone
+func (r *DriftReport) HasDrift() bool {
+	for _, rd := range r.Resources {
+		if rd.Status != DriftUnchanged {
+			return true
+		}
+	}
+	return false
+}
+
+// Refresh updates the state with the real infrastructure, without planning or
+// applying any change, and returns a report of the resources that changed or
+// are gone.
+func (p *Platform) Refresh() (*DriftReport, error) {
+	return p.RefreshContext(context.Background())
+}
+
+// RefreshContext is like Refresh but it watches the given context. If the
+// context is cancelled or its deadline exceeded, Terraform is stopped and a
+// *CancelError is returned.
+func (p *Platform) RefreshContext(ctx context.Context) (report *DriftReport, err error) {
+	p.startMiddleware()
+	p.resetWarnings()
+
+	unlock, err := p.lockState(ctx, "refresh")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock(&err)
+
+	prior := states.NewState()
+	if p.State != nil {
+		prior = p.State.DeepCopy()
+	}
+
+	tfCtx, _, err := p.newContext(false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stop, _ := stopOnDone(ctx, tfCtx)
+	defer stop()
+
+	refreshed, diag := tfCtx.Refresh()
+	p.addWarnings(diag, codeDir(tfCtx.Config()))
+	if err := cancelled(ctx, "refresh"); err != nil {
+		return nil, err
+	}
+	if diag.HasErrors() {
+		return nil, newDiagnosticsError(diag, tfCtx.Config())
+	}
+
+	report, err = newDriftReport(prior, refreshed)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.updateState(refreshed); err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// newDriftReport compares every managed resource instance in the prior state
+// with the refreshed state
+func newDriftReport(prior, refreshed *State) (*DriftReport, error) {
+	report := &DriftReport{}
+
+	for _, addr := range resourceInstances(prior, addrs.ManagedResourceMode) {
+		before := prior.ResourceInstance(addr).Current
+		if before == nil {
+			continue
+		}
+
+		rd := ResourceDrift{
+			Addr:   addr.String(),
+			Status: DriftUnchanged,
+		}
+
+		ri := refreshed.ResourceInstance(addr)
+		if ri == nil || ri.Current == nil {
+			rd.Status = DriftGone
+			report.Resources = append(report.Resources, rd)
+			continue
+		}
+
+		attrs, err := attributesDrift(before, ri.Current)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare the attributes of %s. %s", addr, err)
+		}
+		if len(attrs) != 0 {
+			rd.Status = DriftChanged
+			rd.Attributes = attrs
+		}
+
+		report.Resources = append(report.Resources, rd)
+	}
+
+	return report, nil
+}
+
+// attributesDrift returns the attributes with different values in both objects
+func attributesDrift(before, after *states.ResourceInstanceObjectSrc) ([]AttributeDrift, error) {
+	beforeAttrs, err := attributesMap(before)
+	if err != nil {
+		return nil, err
+	}
+	afterAttrs, err := attributesMap(after)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]struct{}{}
+	for name := range beforeAttrs {
+		names[name] = struct{}{}
+	}
+	for name := range afterAttrs {
+		names[name] = struct{}{}
+	}
+	sortedNames := make([]string, 0, len(names))
+	for name := range names {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+
+	var attrs []AttributeDrift
+	for _, name := range sortedNames {
+		if reflect.DeepEqual(beforeAttrs[name], afterAttrs[name]) {
+			continue
+		}
+		attrs = append(attrs, AttributeDrift{
+			Name:   name,
+			Before: beforeAttrs[name],
+			After:  afterAttrs[name],
+		})
+	}
+
+	return attrs, nil
+}
+
+// attributesMap decodes the attributes of the given object into a map
+func attributesMap(obj *states.ResourceInstanceObjectSrc) (map[string]interface{}, error) {
+	attrs := map[string]interface{}{}
+	if obj.AttrsJSON != nil {
+		if err := json.Unmarshal(obj.AttrsJSON, &attrs); err != nil {
+			return nil, err
+		}
+		return attrs, nil
+	}
+	for name, value := range obj.AttrsFlat {
+		attrs[name] = value
+	}
+	return attrs, nil
+}
@@ -0,0 +1,130 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/providers"
+	"github.com/hashicorp/terraform/states"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestPlatform_Refresh(t *testing.T) {
+	tests := []struct {
+		name      string
+		ids       []string
+		want      *DriftReport
+		wantDrift bool
+	}{
+		{"empty state", nil, &DriftReport{}, false},
+		{"unchanged", []string{"same"}, &DriftReport{
+			Resources: []ResourceDrift{
+				{Addr: "test_instance.same", Status: DriftUnchanged},
+			},
+		}, false},
+		{"changed and gone", []string{"changed", "gone", "same"}, &DriftReport{
+			Resources: []ResourceDrift{
+				{Addr: "test_instance.changed", Status: DriftChanged, Attributes: []AttributeDrift{
+					{Name: "ami", Before: "bar", After: "baz"},
+				}},
+				{Addr: "test_instance.gone", Status: DriftGone},
+				{Addr: "test_instance.same", Status: DriftUnchanged},
+			},
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPlatformForTest(platformFields{
+				Code:  testInstancesCode(tt.ids...),
+				State: testInstancesState(tt.ids...),
+				Providers: map[string]providers.Factory{
+					"test": providers.FactoryFixed(newDriftMockProvider()),
+				},
+			})
+
+			got, err := p.Refresh()
+			if err != nil {
+				t.Fatalf("Platform.Refresh() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Platform.Refresh() = %+v, want %+v", got, tt.want)
+			}
+			if got.HasDrift() != tt.wantDrift {
+				t.Errorf("DriftReport.HasDrift() = %v, want %v", got.HasDrift(), tt.wantDrift)
+			}
+			if tt.wantDrift && p.State.ResourceInstance(testInstanceAddr("gone")) != nil {
+				t.Errorf("Platform.Refresh() did not update the state, the gone instance is still there")
+			}
+		})
+	}
+}
+
+// newDriftMockProvider creates a MockProvider where the instance with id
+// "changed" has a different ami and the instance with id "gone" doesn't exists
+func newDriftMockProvider() providers.Interface {
+	p := NewMockProvider(nil, "test", testSimpleSchema())
+	p.ReadResourceFn = func(req providers.ReadResourceRequest) providers.ReadResourceResponse {
+		switch req.PriorState.GetAttr("id").AsString() {
+		case "gone":
+			return providers.ReadResourceResponse{NewState: cty.NullVal(req.PriorState.Type())}
+		case "changed":
+			return providers.ReadResourceResponse{NewState: cty.ObjectVal(map[string]cty.Value{
+				"id":  cty.StringVal("changed"),
+				"ami": cty.StringVal("baz"),
+			})}
+		}
+		return providers.ReadResourceResponse{NewState: req.PriorState}
+	}
+	return p
+}
+
+func testInstancesCode(ids ...string) string {
+	code := "# test instances\n"
+	for _, id := range ids {
+		code += fmt.Sprintf("resource \"test_instance\" %q {\n  ami = \"bar\"\n}\n", id)
+	}
+	return code
+}
+
+func testInstanceAddr(name string) addrs.AbsResourceInstance {
+	return addrs.Resource{
+		Mode: addrs.ManagedResourceMode,
+		Type: "test_instance",
+		Name: name,
+	}.Instance(addrs.NoKey).Absolute(addrs.RootModuleInstance)
+}
+
+// testInstancesState creates a state with one test_instance per given id, the
+// name of each instance is also the id
+func testInstancesState(ids ...string) *State {
+	state := states.NewState()
+	for _, id := range ids {
+		state.RootModule().SetResourceInstanceCurrent(
+			testInstanceAddr(id).Resource,
+			&states.ResourceInstanceObjectSrc{
+				Status:    states.ObjectReady,
+				AttrsJSON: []byte(fmt.Sprintf(`{"id":%q,"ami":"bar"}`, id)),
+			},
+			addrs.ProviderConfig{Type: addrs.NewLegacyProvider("test")}.Absolute(addrs.RootModuleInstance),
+		)
+	}
+	return state
+}
@@ -21,22 +21,39 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"sort"
 
+	"github.com/hashicorp/terraform/addrs"
 	"github.com/hashicorp/terraform/states/statemgr"
 
 	"github.com/hashicorp/terraform/states/statefile"
 )
 
-// WriteState takes a io.Writer as input to write the Terraform state
+// WriteState takes a io.Writer as input to write the Terraform state, including
+// its lineage and serial
 func (p *Platform) WriteState(w io.Writer) (*Platform, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	sf := statefile.New(p.State, "", 0)
-	return p, statefile.Write(sf, w)
+	return p, p.writeStateTo(w)
 }
 
-// ReadState takes a io.Reader as input to read from it the Terraform state
+// writeStateTo writes the platform state, encrypted if there are keys, to the
+// given writer. The platform lock has to be held by the caller.
+func (p *Platform) writeStateTo(w io.Writer) error {
+	sf := p.stateFile(p.State)
+	if p.keys != nil {
+		var err error
+		if sf, err = encryptStateFile(sf, p.keys); err != nil {
+			return err
+		}
+	}
+	return statefile.Write(sf, w)
+}
+
+// ReadState takes a io.Reader as input to read from it the Terraform state. The
+// lineage and serial of the state are kept to be used when the state is written
+// again. A new lineage is generated if the state doesn't have one.
 func (p *Platform) ReadState(r io.Reader) (*Platform, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -45,17 +62,39 @@ func (p *Platform) ReadState(r io.Reader) (*Platform, error) {
 	if err != nil {
 		return p, err
 	}
-	p.State = sf.State
+	if sf, err = decryptStateFile(sf, p.keys); err != nil {
+		return p, err
+	}
+	p.setStateFile(sf)
 	return p, nil
 }
 
-// WriteStateToFile save the state of the Terraform state to a file
+// WriteStateToFile save the state of the Terraform state to a file. It fails if
+// the file contains a state with a different lineage or a higher serial.
 func (p *Platform) WriteStateToFile(filename string) (*Platform, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if file, err := os.Open(filename); err == nil {
+		stored, err := statefile.Read(file)
+		file.Close()
+		if err == nil {
+			if err := p.checkStoredState(stored); err != nil {
+				return p, err
+			}
+		}
+	}
+
 	var state bytes.Buffer
-	if _, err := p.WriteState(&state); err != nil {
+	if err := p.writeStateTo(&state); err != nil {
 		return p, err
 	}
-	return p, ioutil.WriteFile(filename, state.Bytes(), 0644)
+	if err := ioutil.WriteFile(filename, state.Bytes(), 0644); err != nil {
+		return p, err
+	}
+	p.storedSerial = p.serial
+
+	return p, nil
 }
 
 // ReadStateFromFile will load the Terraform state from a file and assign it to the
@@ -71,23 +110,114 @@ func (p *Platform) ReadStateFromFile(filename string) (*Platform, error) {
 
 // PersistStateToFile reads the state from the given file, if exists. Then will save
 // the current state to the given file every time it changes during the Terraform
-// actions.
+// actions. The previous state file is kept as a backup with extension `.bkp`.
 func (p *Platform) PersistStateToFile(filename string) (*Platform, error) {
-	if _, err := os.Stat(filename); !os.IsNotExist(err) {
-		// If the file exists, read the state from the file and make it a backup of the file
-		if _, err := p.ReadStateFromFile(filename); err != nil {
-			return p, err
+	return p.PersistStateTo(NewFileBackend(filename))
+}
+
+// stateWriter is the state manager used by the Terraform hooks to save the
+// state every time it changes with the platform lineage and serial
+type stateWriter struct {
+	p *Platform
+}
+
+func (w *stateWriter) WriteState(state *State) error {
+	w.p.mu.Lock()
+	defer w.p.mu.Unlock()
+
+	return w.p.writeState(state)
+}
+
+// writeState saves the given state to the state backend, if there is one,
+// using the platform lineage and serial. It fails if the backend has a state
+// with a different lineage or a higher serial. The platform lock has to be held
+// by the caller.
+func (p *Platform) writeState(state *State) error {
+	if p.backend == nil {
+		return nil
+	}
+
+	stored, err := p.backend.ReadState(p.workspace)
+	if err != nil {
+		return err
+	}
+	if err := p.checkStoredState(stored); err != nil {
+		return err
+	}
+
+	sf := p.stateFile(state)
+	if atomic, ok := p.backend.(AtomicStateBackend); ok {
+		err = atomic.CompareAndWriteState(p.workspace, sf, p.storedSerial)
+	} else {
+		err = p.backend.WriteState(p.workspace, sf)
+	}
+	if err != nil {
+		return err
+	}
+	p.storedSerial = p.serial
+
+	return nil
+}
+
+// checkStoredState returns a *StateConflictError if the stored state can't be
+// overwritten by the platform state because it belongs to another lineage or
+// it was saved by someone else after the platform read or saved it
+func (p *Platform) checkStoredState(stored *statefile.File) error {
+	if stored == nil || stored.State == nil || len(stored.Lineage) == 0 {
+		return nil
+	}
+	if stored.Lineage != p.lineage || stored.Serial > p.storedSerial {
+		return &StateConflictError{
+			Lineage:       p.lineage,
+			Serial:        p.storedSerial,
+			StoredLineage: stored.Lineage,
+			StoredSerial:  stored.Serial,
 		}
-		os.Rename(filename, filename+".bkp")
 	}
+	return nil
+}
 
-	// The files does not exists, create it with the current state: empty or loaded
-	if _, err := p.WriteStateToFile(filename); err != nil {
-		return p, err
+// setStateFile makes the state of the given state file the platform state,
+// keeping its lineage and serial
+func (p *Platform) setStateFile(sf *statefile.File) {
+	p.State = sf.State
+	p.lineage, p.serial, p.storedSerial = sf.Lineage, sf.Serial, sf.Serial
+	if len(p.lineage) == 0 {
+		p.lineage = statemgr.NewLineage()
 	}
+}
 
-	fsStateMgr := statemgr.NewFilesystem(filename)
-	p.stateMgr = fsStateMgr
+// stateFile returns the given state as a state file with the platform lineage
+// and serial, generating the lineage if there is none
+func (p *Platform) stateFile(state *State) *statefile.File {
+	if len(p.lineage) == 0 {
+		p.lineage = statemgr.NewLineage()
+	}
+	return statefile.New(state, p.lineage, p.serial)
+}
 
-	return p, nil
+// resourceInstances returns the address of every resource instance of the given
+// mode in all the modules of the state, sorted by address
+func resourceInstances(state *State, mode addrs.ResourceMode) []addrs.AbsResourceInstance {
+	var instances []addrs.AbsResourceInstance
+	if state == nil {
+		return instances
+	}
+
+	for _, m := range state.Modules {
+		for _, r := range m.Resources {
+			if r.Addr.Mode != mode {
+				continue
+			}
+			for key := range r.Instances {
+				instances = append(instances, r.Addr.Instance(key).Absolute(m.Addr))
+			}
+		}
+	}
+
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].String() < instances[j].String()
+	})
+
+	return instances
 }
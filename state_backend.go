@@ -0,0 +1,110 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"github.com/hashicorp/terraform/backend"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/hashicorp/terraform/states/statemgr"
+)
+
+// DefaultWorkspace is the name of the workspace used when no other workspace
+// is selected
+const DefaultWorkspace = backend.DefaultStateName
+
+// LockInfo is an alias for statemgr.LockInfo, the information about who holds
+// the lock of a state
+type LockInfo = statemgr.LockInfo
+
+// StateBackend is a storage for the state of every workspace of a platform.
+// The state is read and written with its lineage and serial, so the platform
+// can detect when it was modified by someone else.
+type StateBackend interface {
+	// ReadState returns the state of the given workspace, or nil if there is no
+	// state stored
+	ReadState(workspace string) (*statefile.File, error)
+	// WriteState stores the state of the given workspace
+	WriteState(workspace string, sf *statefile.File) error
+	// Lock locks the state of the given workspace, returning the lock ID to
+	// unlock it, or a *StateLockedError if it's already locked
+	Lock(workspace string, info *LockInfo) (string, error)
+	// Unlock unlocks the state of the given workspace locked with the given ID
+	Unlock(workspace string, id string) error
+	// Workspaces returns the default workspace followed by the sorted list of
+	// the other workspaces with a state
+	Workspaces() ([]string, error)
+	// DeleteWorkspace deletes the state of the given workspace. The default
+	// workspace cannot be deleted.
+	DeleteWorkspace(workspace string) error
+}
+
+// AtomicStateBackend is a StateBackend able to verify the stored state and
+// write the new one atomically, so concurrent writers cannot overwrite the
+// changes of each other
+type AtomicStateBackend interface {
+	StateBackend
+	// CompareAndWriteState stores the state of the given workspace if the stored
+	// state has the same lineage and a serial not higher than the given one, the
+	// serial of the state read or saved by the writer. Otherwise returns a
+	// *StateConflictError.
+	CompareAndWriteState(workspace string, sf *statefile.File, serial uint64) error
+}
+
+// PersistStateTo reads the state of the selected workspace from the given
+// backend, if there is one. Then will save the current state to the backend
+// every time it changes during the Terraform actions.
+func (p *Platform) PersistStateTo(backend StateBackend) (*Platform, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := backend.(*EncryptedBackend); !ok && p.keys != nil {
+		backend = NewEncryptedBackend(backend, p.keys)
+	}
+
+	sf, err := backend.ReadState(p.workspace)
+	if err != nil {
+		return p, err
+	}
+	if isEncryptedStateFile(sf) {
+		return p, ErrStateEncrypted
+	}
+	if sf != nil {
+		p.setStateFile(sf)
+	}
+
+	p.backend = backend
+
+	// The state read is not saved again, it would be the same lineage and serial
+	if sf != nil && sf.Lineage == p.lineage && sf.Serial == p.serial {
+		return p, nil
+	}
+	return p, p.writeState(p.State)
+}
+
+// newLockInfo returns a copy of the given lock information, with an ID if it
+// doesn't have one
+func newLockInfo(info *LockInfo) *LockInfo {
+	lockInfo := statemgr.NewLockInfo()
+	if info != nil {
+		id := lockInfo.ID
+		*lockInfo = *info
+		if len(lockInfo.ID) == 0 {
+			lockInfo.ID = id
+		}
+	}
+	return lockInfo
+}
@@ -0,0 +1,389 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform/states/statefile"
+)
+
+// workspacesDir is the directory, next to the state file, with the state of
+// every workspace other than the default, like the Terraform local backend
+const workspacesDir = "terraform.tfstate.d"
+
+// snapshotFilenameFormat is the format of the name of the snapshot files in the
+// history directory, with the serial padded to sort them by name, and the
+// escaped lineage
+const snapshotFilenameFormat = "%020d-%s.tfstate"
+
+// FileBackend is a StateBackend saving the states to files. The state of the
+// default workspace is saved to the given file, and the state of any other
+// workspace to a file with the same name in the directory
+// `terraform.tfstate.d/<workspace>` next to it. The first time a state file is
+// written, the previous one is kept in a backup file with extension `.bkp`.
+// If KeepHistory is set, every state written is also kept, with the given
+// retention policy, in the directory with the name of the state file and
+// extension `.history`.
+type FileBackend struct {
+	Filename    string
+	KeepHistory bool
+	Retention   RetentionPolicy
+	mu          sync.Mutex
+	backedUp    map[string]bool
+}
+
+// NewFileBackend creates a FileBackend with the given state file for the
+// default workspace. The state history is not kept unless KeepHistory is set,
+// then the default retention policy is used.
+func NewFileBackend(filename string) *FileBackend {
+	return &FileBackend{
+		Filename:  filename,
+		Retention: DefaultRetentionPolicy,
+		backedUp:  map[string]bool{},
+	}
+}
+
+// ReadState reads the state file of the given workspace
+func (b *FileBackend) ReadState(workspace string) (*statefile.File, error) {
+	file, err := os.Open(b.statePath(workspace))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sf, err := statefile.Read(file)
+	if err == statefile.ErrNoState {
+		return nil, nil
+	}
+	return sf, err
+}
+
+// WriteState writes the state file of the given workspace
+func (b *FileBackend) WriteState(workspace string, sf *statefile.File) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	filename := b.statePath(workspace)
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+
+	// Keep a backup of the state file as it was before being written the first time
+	if !b.backedUp[workspace] {
+		backup, err := b.backupRequired(workspace, sf)
+		if err != nil {
+			return err
+		}
+		if backup {
+			if err := os.Rename(filename, filename+".bkp"); err != nil {
+				return err
+			}
+		}
+		b.backedUp[workspace] = true
+	}
+
+	if err := writeStateFile(filename, sf); err != nil {
+		return err
+	}
+
+	if !b.KeepHistory {
+		return nil
+	}
+	return b.addSnapshot(workspace, sf)
+}
+
+// backupRequired returns true if there is a state file of the given workspace
+// to back up before writing the given state. A state file that is not encrypted
+// is not backed up when the given state is encrypted, the backup would keep the
+// state not encrypted.
+func (b *FileBackend) backupRequired(workspace string, sf *statefile.File) (bool, error) {
+	if _, err := os.Stat(b.statePath(workspace)); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if !isEncryptedStateFile(sf) {
+		return true, nil
+	}
+	stored, err := b.ReadState(workspace)
+	return err == nil && isEncryptedStateFile(stored), nil
+}
+
+// History returns the snapshots in the history directory of the given
+// workspace. The serial and lineage of the snapshots are taken from the file
+// names, so the snapshots are not read.
+func (b *FileBackend) History(workspace string) ([]StateSnapshot, error) {
+	entries, err := ioutil.ReadDir(b.historyPath(workspace))
+	if os.IsNotExist(err) {
+		return []StateSnapshot{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]StateSnapshot, 0, len(entries))
+	for _, entry := range entries {
+		snapshot, ok := parseSnapshotFilename(entry.Name())
+		if !ok {
+			continue
+		}
+		snapshot.Created = entry.ModTime()
+		snapshots = append(snapshots, snapshot)
+	}
+	sortSnapshots(snapshots)
+
+	return snapshots, nil
+}
+
+// ReadSnapshot reads the snapshot of the state of the given workspace with the
+// given serial from the history directory
+func (b *FileBackend) ReadSnapshot(workspace string, serial uint64) (*statefile.File, error) {
+	snapshots, err := b.History(workspace)
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Serial != serial {
+			continue
+		}
+		file, err := os.Open(b.snapshotPath(workspace, snapshot))
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		return statefile.Read(file)
+	}
+
+	return nil, fmt.Errorf("%w: serial %d", ErrSnapshotNotFound, serial)
+}
+
+// addSnapshot saves the given state to the history directory, replacing any
+// snapshot with the same serial, and removes the expired snapshots. The
+// backend lock has to be held by the caller.
+func (b *FileBackend) addSnapshot(workspace string, sf *statefile.File) error {
+	if err := os.MkdirAll(b.historyPath(workspace), 0755); err != nil {
+		return err
+	}
+
+	snapshots, err := b.History(workspace)
+	if err != nil {
+		return err
+	}
+	added := StateSnapshot{Serial: sf.Serial, Lineage: sf.Lineage}
+	for _, snapshot := range snapshots {
+		if snapshot.Serial == sf.Serial && snapshot.Lineage != sf.Lineage {
+			if err := os.Remove(b.snapshotPath(workspace, snapshot)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := writeStateFile(b.snapshotPath(workspace, added), sf); err != nil {
+		return err
+	}
+
+	if snapshots, err = b.History(workspace); err != nil {
+		return err
+	}
+	for _, snapshot := range b.Retention.expired(snapshots) {
+		if err := os.Remove(b.snapshotPath(workspace, snapshot)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Lock locks the state of the given workspace creating a lock file next to the
+// state file with the lock information
+func (b *FileBackend) Lock(workspace string, info *LockInfo) (string, error) {
+	filename := b.statePath(workspace)
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return "", err
+	}
+
+	lockInfo := newLockInfo(info)
+	lockInfo.Path = filename
+
+	file, err := os.OpenFile(lockPath(filename), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return "", b.lockedError(workspace)
+	}
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.Write(lockInfo.Marshal()); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	return lockInfo.ID, file.Close()
+}
+
+// Unlock unlocks the state of the given workspace removing the lock file
+func (b *FileBackend) Unlock(workspace string, id string) error {
+	lockInfo, err := b.lockInfo(workspace)
+	if err != nil {
+		return err
+	}
+	if lockInfo.ID != id {
+		return &StateLockedError{Workspace: workspace, Info: lockInfo}
+	}
+	return os.Remove(lockPath(b.statePath(workspace)))
+}
+
+// Workspaces returns the default workspace and the workspaces with a state
+// file in the workspaces directory
+func (b *FileBackend) Workspaces() ([]string, error) {
+	workspaces := []string{DefaultWorkspace}
+
+	entries, err := ioutil.ReadDir(filepath.Join(filepath.Dir(b.Filename), workspacesDir))
+	if os.IsNotExist(err) {
+		return workspaces, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var others []string
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == DefaultWorkspace {
+			continue
+		}
+		if _, err := os.Stat(b.statePath(entry.Name())); err == nil {
+			others = append(others, entry.Name())
+		}
+	}
+	sort.Strings(others)
+
+	return append(workspaces, others...), nil
+}
+
+// DeleteWorkspace deletes the directory with the state of the given workspace
+func (b *FileBackend) DeleteWorkspace(workspace string) error {
+	if workspace == DefaultWorkspace {
+		return ErrDefaultWorkspace
+	}
+	if _, err := os.Stat(lockPath(b.statePath(workspace))); err == nil {
+		return b.lockedError(workspace)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.backedUp, workspace)
+	return os.RemoveAll(filepath.Dir(b.statePath(workspace)))
+}
+
+// historyPath returns the history directory of the given workspace
+func (b *FileBackend) historyPath(workspace string) string {
+	return b.statePath(workspace) + ".history"
+}
+
+// snapshotPath returns the file of the given snapshot in the history directory
+// of the given workspace
+func (b *FileBackend) snapshotPath(workspace string, snapshot StateSnapshot) string {
+	return filepath.Join(b.historyPath(workspace), fmt.Sprintf(snapshotFilenameFormat, snapshot.Serial, url.PathEscape(snapshot.Lineage)))
+}
+
+// parseSnapshotFilename returns the snapshot with the serial and lineage in the
+// given snapshot file name, or false if it's not a snapshot file
+func parseSnapshotFilename(name string) (StateSnapshot, bool) {
+	const serialLen = 20
+	if len(name) <= serialLen || name[serialLen] != '-' || !strings.HasSuffix(name, ".tfstate") {
+		return StateSnapshot{}, false
+	}
+	serial, err := strconv.ParseUint(name[:serialLen], 10, 64)
+	if err != nil {
+		return StateSnapshot{}, false
+	}
+	lineage, err := url.PathUnescape(strings.TrimSuffix(name[serialLen+1:], ".tfstate"))
+	if err != nil {
+		return StateSnapshot{}, false
+	}
+	return StateSnapshot{Serial: serial, Lineage: lineage}, true
+}
+
+// statePath returns the state file of the given workspace
+func (b *FileBackend) statePath(workspace string) string {
+	if workspace == DefaultWorkspace {
+		return b.Filename
+	}
+	return filepath.Join(filepath.Dir(b.Filename), workspacesDir, workspace, filepath.Base(b.Filename))
+}
+
+// lockInfo reads the lock information of the given workspace. A lock file that
+// can't be parsed, left by a process that crashed while locking the state, is
+// a lock without ID created when the file was modified.
+func (b *FileBackend) lockInfo(workspace string) (*LockInfo, error) {
+	filename := lockPath(b.statePath(workspace))
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, ErrStateNotLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lockInfo := &LockInfo{}
+	if err := json.Unmarshal(content, lockInfo); err != nil {
+		lockInfo = &LockInfo{Path: b.statePath(workspace)}
+		if stat, err := os.Stat(filename); err == nil {
+			lockInfo.Created = stat.ModTime()
+		}
+	}
+	return lockInfo, nil
+}
+
+// lockedError returns the error for the locked state of the given workspace,
+// with the lock information if it can be read
+func (b *FileBackend) lockedError(workspace string) error {
+	lockInfo, _ := b.lockInfo(workspace)
+	return &StateLockedError{Workspace: workspace, Info: lockInfo}
+}
+
+// writeStateFile writes the given state to the given file
+func writeStateFile(filename string, sf *statefile.File) error {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if err := statefile.Write(sf, file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// lockPath returns the lock file of the given state file
+func lockPath(filename string) string {
+	return filename + ".lock.info"
+}
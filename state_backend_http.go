@@ -0,0 +1,333 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform/states/statefile"
+)
+
+// HTTPBackend is a StateBackend saving the state to a REST server using the
+// same protocol of the Terraform `http` backend: the state is read with GET,
+// written with POST and deleted with DELETE to the given address, and locked
+// and unlocked sending the lock information to the lock and unlock addresses.
+// As the Terraform `http` backend, only the default workspace is supported.
+// If Client is not set, a client is created with the first request, so
+// SkipCertVerification has to be set before using the backend.
+type HTTPBackend struct {
+	Address              string
+	UpdateMethod         string
+	LockAddress          string
+	LockMethod           string
+	UnlockAddress        string
+	UnlockMethod         string
+	Username             string
+	Password             string
+	Headers              map[string]string
+	SkipCertVerification bool
+	RetryMax             int
+	RetryWaitMin         time.Duration
+	RetryWaitMax         time.Duration
+	Client               *http.Client
+	mu                   sync.Mutex
+	lockInfo             *LockInfo
+	clientOnce           sync.Once
+	client               *http.Client
+}
+
+// NewHTTPBackend creates a HTTPBackend for the given state address, using the
+// same address to lock and unlock the state and the default values of the
+// Terraform `http` backend.
+func NewHTTPBackend(address string) *HTTPBackend {
+	return &HTTPBackend{
+		Address:       address,
+		UpdateMethod:  http.MethodPost,
+		LockAddress:   address,
+		LockMethod:    "LOCK",
+		UnlockAddress: address,
+		UnlockMethod:  "UNLOCK",
+		RetryMax:      2,
+		RetryWaitMin:  time.Second,
+		RetryWaitMax:  30 * time.Second,
+	}
+}
+
+// ReadState gets the state from the state address
+func (b *HTTPBackend) ReadState(workspace string) (*statefile.File, error) {
+	if workspace != DefaultWorkspace {
+		return nil, ErrWorkspacesNotSupported
+	}
+
+	resp, body, err := b.do(http.MethodGet, b.Address, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent, http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, httpStatusError("get the state", resp)
+	}
+
+	if len(body) == 0 {
+		return nil, nil
+	}
+	if md5Header := resp.Header.Get("Content-MD5"); len(md5Header) != 0 {
+		if md5Header != contentMD5(body) {
+			return nil, fmt.Errorf("failed to get the state, the MD5 checksum %q doesn't match the content", md5Header)
+		}
+	}
+
+	sf, err := statefile.Read(bytes.NewReader(body))
+	if err == statefile.ErrNoState {
+		return nil, nil
+	}
+	return sf, err
+}
+
+// WriteState sends the state to the state address, with the lock ID if it's
+// locked
+func (b *HTTPBackend) WriteState(workspace string, sf *statefile.File) error {
+	if workspace != DefaultWorkspace {
+		return ErrWorkspacesNotSupported
+	}
+
+	var body bytes.Buffer
+	if err := statefile.Write(sf, &body); err != nil {
+		return err
+	}
+
+	address := b.Address
+	b.mu.Lock()
+	if b.lockInfo != nil {
+		u, err := url.Parse(address)
+		if err != nil {
+			b.mu.Unlock()
+			return err
+		}
+		query := u.Query()
+		query.Set("ID", b.lockInfo.ID)
+		u.RawQuery = query.Encode()
+		address = u.String()
+	}
+	b.mu.Unlock()
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+		"Content-MD5":  contentMD5(body.Bytes()),
+	}
+	resp, _, err := b.do(b.UpdateMethod, address, body.Bytes(), headers)
+	if err != nil {
+		return err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return nil
+	default:
+		return httpStatusError("save the state", resp)
+	}
+}
+
+// Lock sends the lock information to the lock address. If there is no lock
+// address the state is not locked, as the server doesn't support it. The lock
+// request is not retried, a failed request may have locked the state anyway.
+func (b *HTTPBackend) Lock(workspace string, info *LockInfo) (string, error) {
+	if workspace != DefaultWorkspace {
+		return "", ErrWorkspacesNotSupported
+	}
+	if len(b.LockAddress) == 0 {
+		return "", nil
+	}
+
+	lockInfo := newLockInfo(info)
+	lockInfo.Path = b.Address
+
+	resp, body, err := b.send(b.LockMethod, b.LockAddress, lockInfo.Marshal(), map[string]string{"Content-Type": "application/json"})
+	if err != nil {
+		return "", err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		b.mu.Lock()
+		b.lockInfo = lockInfo
+		b.mu.Unlock()
+		return lockInfo.ID, nil
+	case http.StatusConflict, http.StatusLocked:
+		return "", &StateLockedError{Workspace: workspace, Info: parseLockInfo(body)}
+	default:
+		return "", httpStatusError("lock the state", resp)
+	}
+}
+
+// Unlock sends the lock information to the unlock address
+func (b *HTTPBackend) Unlock(workspace string, id string) error {
+	if workspace != DefaultWorkspace {
+		return ErrWorkspacesNotSupported
+	}
+	if len(b.UnlockAddress) == 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	lockInfo := b.lockInfo
+	b.mu.Unlock()
+	if lockInfo == nil || lockInfo.ID != id {
+		lockInfo = &LockInfo{ID: id}
+	}
+
+	resp, body, err := b.do(b.UnlockMethod, b.UnlockAddress, lockInfo.Marshal(), map[string]string{"Content-Type": "application/json"})
+	if err != nil {
+		return err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		b.mu.Lock()
+		if b.lockInfo != nil && b.lockInfo.ID == id {
+			b.lockInfo = nil
+		}
+		b.mu.Unlock()
+		return nil
+	case http.StatusConflict, http.StatusLocked:
+		return &StateLockedError{Workspace: workspace, Info: parseLockInfo(body)}
+	default:
+		return httpStatusError("unlock the state", resp)
+	}
+}
+
+// Workspaces returns only the default workspace, the only one supported
+func (b *HTTPBackend) Workspaces() ([]string, error) {
+	return []string{DefaultWorkspace}, nil
+}
+
+// DeleteWorkspace fails, the default workspace cannot be deleted and it's the
+// only one supported
+func (b *HTTPBackend) DeleteWorkspace(workspace string) error {
+	if workspace == DefaultWorkspace {
+		return ErrDefaultWorkspace
+	}
+	return ErrWorkspacesNotSupported
+}
+
+// do sends the request, retrying it if there is a connection error or a server
+// error, and returns the response with the content of its body
+func (b *HTTPBackend) do(method, address string, body []byte, headers map[string]string) (*http.Response, []byte, error) {
+	wait := b.RetryWaitMin
+	for retry := 0; ; retry++ {
+		resp, respBody, err := b.send(method, address, body, headers)
+
+		retryable := err != nil || resp.StatusCode == http.StatusTooManyRequests ||
+			(resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented)
+		if !retryable || retry >= b.RetryMax {
+			return resp, respBody, err
+		}
+
+		time.Sleep(wait)
+		if wait *= 2; wait > b.RetryWaitMax {
+			wait = b.RetryWaitMax
+		}
+	}
+}
+
+// send sends the request once and returns the response with the content of
+// its body
+func (b *HTTPBackend) send(method, address string, body []byte, headers map[string]string) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(method, address, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, value := range b.Headers {
+		req.Header.Set(name, value)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	if len(b.Username) != 0 || len(b.Password) != 0 {
+		req.SetBasicAuth(b.Username, b.Password)
+	}
+
+	resp, err := b.httpClient().Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to send %s request to %s. %w", method, address, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to send %s request to %s. %w", method, address, err)
+	}
+	return resp, respBody, nil
+}
+
+// httpClient returns the client to send the requests, if Client is not set the
+// client is created once, with the first request
+func (b *HTTPBackend) httpClient() *http.Client {
+	if b.Client != nil {
+		return b.Client
+	}
+	b.clientOnce.Do(func() {
+		b.client = http.DefaultClient
+		if b.SkipCertVerification {
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+			b.client = &http.Client{Transport: transport}
+		}
+	})
+	return b.client
+}
+
+// httpStatusError returns the error for an unexpected response status
+func httpStatusError(action string, resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return fmt.Errorf("failed to %s, the HTTP state backend requires authentication", action)
+	case http.StatusForbidden:
+		return fmt.Errorf("failed to %s, invalid authentication for the HTTP state backend", action)
+	default:
+		return fmt.Errorf("failed to %s, unexpected HTTP response status %s", action, resp.Status)
+	}
+}
+
+// parseLockInfo returns the lock information in the given content, if any
+func parseLockInfo(content []byte) *LockInfo {
+	lockInfo := &LockInfo{}
+	if err := json.Unmarshal(content, lockInfo); err != nil {
+		return nil
+	}
+	return lockInfo
+}
+
+// contentMD5 returns the base64 encoded MD5 checksum of the given content
+func contentMD5(content []byte) string {
+	sum := md5.Sum(content)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
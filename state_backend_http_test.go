@@ -0,0 +1,218 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/terraform/states/statefile"
+)
+
+func TestHTTPBackend(t *testing.T) {
+	server := &testStateServer{username: "user", password: "secret", failures: 1}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	backend := NewHTTPBackend(ts.URL + "/state")
+	backend.Username, backend.Password = "user", "secret"
+	backend.Headers = map[string]string{"X-Team": "infra"}
+	backend.RetryWaitMin, backend.RetryWaitMax = 0, 0
+
+	// States
+	if sf, err := backend.ReadState(DefaultWorkspace); sf != nil || err != nil {
+		t.Errorf("HTTPBackend.ReadState() of an empty server = %v, %v, want nil", sf, err)
+	}
+	want := statefile.New(testInstancesState("foo"), "test", 3)
+	if err := backend.WriteState(DefaultWorkspace, want); err != nil {
+		t.Fatalf("HTTPBackend.WriteState() error = %v", err)
+	}
+	got, err := backend.ReadState(DefaultWorkspace)
+	if err != nil {
+		t.Fatalf("HTTPBackend.ReadState() error = %v", err)
+	}
+	if got == nil || got.Lineage != want.Lineage || got.Serial != want.Serial || !statefile.StatesMarshalEqual(got.State, want.State) {
+		t.Errorf("HTTPBackend.ReadState() = %+v, want %+v", got, want)
+	}
+	if server.team != "infra" {
+		t.Errorf("HTTPBackend did not send the custom headers")
+	}
+
+	// Locks
+	id, err := backend.Lock(DefaultWorkspace, &LockInfo{Operation: "test"})
+	if err != nil || len(id) == 0 {
+		t.Fatalf("HTTPBackend.Lock() = %q, %v, want a lock ID", id, err)
+	}
+	if err := backend.WriteState(DefaultWorkspace, want); err != nil {
+		t.Errorf("HTTPBackend.WriteState() of a locked state error = %v", err)
+	}
+	if server.updateID != id {
+		t.Errorf("HTTPBackend.WriteState() sent lock ID %q, want %q", server.updateID, id)
+	}
+	var lockedErr *StateLockedError
+	other := NewHTTPBackend(ts.URL + "/state")
+	other.Username, other.Password = "user", "secret"
+	if _, err := other.Lock(DefaultWorkspace, &LockInfo{Operation: "other"}); !errors.As(err, &lockedErr) {
+		t.Errorf("HTTPBackend.Lock() of a locked state error = %v, want %T", err, lockedErr)
+	} else if lockedErr.Info == nil || lockedErr.Info.ID != id || lockedErr.Info.Operation != "test" {
+		t.Errorf("HTTPBackend.Lock() of a locked state info = %+v, want the lock with ID %q", lockedErr.Info, id)
+	}
+	if err := other.Unlock(DefaultWorkspace, "wrong"); !errors.As(err, &lockedErr) {
+		t.Errorf("HTTPBackend.Unlock() with a wrong ID error = %v, want %T", err, lockedErr)
+	}
+	if err := backend.Unlock(DefaultWorkspace, id); err != nil {
+		t.Errorf("HTTPBackend.Unlock() error = %v", err)
+	}
+
+	// Workspaces
+	if _, err := backend.ReadState("staging"); !errors.Is(err, ErrWorkspacesNotSupported) {
+		t.Errorf("HTTPBackend.ReadState() of other workspace error = %v, want %v", err, ErrWorkspacesNotSupported)
+	}
+	if err := backend.DeleteWorkspace(DefaultWorkspace); !errors.Is(err, ErrDefaultWorkspace) {
+		t.Errorf("HTTPBackend.DeleteWorkspace() error = %v, want %v", err, ErrDefaultWorkspace)
+	}
+
+	// Authentication and retries
+	unauthorized := NewHTTPBackend(ts.URL + "/state")
+	if _, err := unauthorized.ReadState(DefaultWorkspace); err == nil {
+		t.Errorf("HTTPBackend.ReadState() without authentication expected an error")
+	}
+	server.failures = 5
+	if _, err := backend.ReadState(DefaultWorkspace); err == nil {
+		t.Errorf("HTTPBackend.ReadState() expected an error after %d retries", backend.RetryMax)
+	}
+	if server.failures != 5-(backend.RetryMax+1) {
+		t.Errorf("HTTPBackend sent %d requests, want %d", 5-server.failures, backend.RetryMax+1)
+	}
+	server.failures = 5
+	if _, err := backend.Lock(DefaultWorkspace, &LockInfo{Operation: "test"}); err == nil {
+		t.Errorf("HTTPBackend.Lock() expected an error")
+	}
+	if server.failures != 4 {
+		t.Errorf("HTTPBackend.Lock() sent %d requests, want 1", 5-server.failures)
+	}
+
+	// Platform
+	server.failures = 0
+	p, err := NewPlatform("").PersistStateTo(backend)
+	if err != nil {
+		t.Fatalf("Platform.PersistStateTo() error = %v", err)
+	}
+	if got := p.StateList(); len(got) != 1 || got[0] != "test_instance.foo" {
+		t.Errorf("Platform.PersistStateTo() state = %v, want [test_instance.foo]", got)
+	}
+}
+
+func TestHTTPBackend_SkipCertVerification(t *testing.T) {
+	server := &testStateServer{username: "user", password: "secret"}
+	ts := httptest.NewTLSServer(server)
+	defer ts.Close()
+
+	backend := NewHTTPBackend(ts.URL + "/state")
+	backend.Username, backend.Password = "user", "secret"
+	backend.RetryMax = 0
+	if _, err := backend.ReadState(DefaultWorkspace); err == nil {
+		t.Errorf("HTTPBackend.ReadState() of a server with an unknown certificate expected an error")
+	}
+
+	backend = NewHTTPBackend(ts.URL + "/state")
+	backend.Username, backend.Password = "user", "secret"
+	backend.SkipCertVerification = true
+	for i := 0; i < 2; i++ {
+		if _, err := backend.ReadState(DefaultWorkspace); err != nil {
+			t.Errorf("HTTPBackend.ReadState() error = %v", err)
+		}
+	}
+	if client := backend.httpClient(); client == http.DefaultClient || client != backend.client {
+		t.Errorf("HTTPBackend.httpClient() = %p, want the client created once %p", client, backend.client)
+	}
+}
+
+// testStateServer is a HTTP state server like the ones supported by the
+// Terraform `http` backend
+type testStateServer struct {
+	mu                 sync.Mutex
+	username, password string
+	failures           int
+	state              []byte
+	lock               *LockInfo
+	updateID           string
+	team               string
+}
+
+func (s *testStateServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failures > 0 {
+		s.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if username, password, ok := r.BasicAuth(); !ok || username != s.username || password != s.password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if team := r.Header.Get("X-Team"); len(team) != 0 {
+		s.team = team
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+
+	switch r.Method {
+	case http.MethodGet:
+		if s.state == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-MD5", contentMD5(s.state))
+		w.Write(s.state)
+	case http.MethodPost:
+		if r.Header.Get("Content-MD5") != contentMD5(body) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.updateID = r.URL.Query().Get("ID")
+		s.state = body
+	case http.MethodDelete:
+		s.state = nil
+	case "LOCK":
+		if s.lock != nil {
+			w.WriteHeader(http.StatusLocked)
+			w.Write(s.lock.Marshal())
+			return
+		}
+		s.lock = &LockInfo{}
+		json.Unmarshal(body, s.lock)
+	case "UNLOCK":
+		lockInfo := &LockInfo{}
+		json.Unmarshal(body, lockInfo)
+		if s.lock != nil && s.lock.ID != lockInfo.ID {
+			w.WriteHeader(http.StatusConflict)
+			w.Write(s.lock.Marshal())
+			return
+		}
+		s.lock = nil
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
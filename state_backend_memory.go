@@ -0,0 +1,185 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform/states/statefile"
+)
+
+// MemoryBackend is a StateBackend keeping the states, and the history of the
+// states with the given retention policy, in memory. Mostly useful for testing.
+type MemoryBackend struct {
+	Retention RetentionPolicy
+	mu        sync.Mutex
+	states    map[string]*statefile.File
+	locks     map[string]*LockInfo
+	history   map[string][]memorySnapshot
+}
+
+type memorySnapshot struct {
+	StateSnapshot
+	sf *statefile.File
+}
+
+// NewMemoryBackend creates an empty MemoryBackend with the default retention
+// policy
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		Retention: DefaultRetentionPolicy,
+		states:    map[string]*statefile.File{},
+		locks:     map[string]*LockInfo{},
+		history:   map[string][]memorySnapshot{},
+	}
+}
+
+// ReadState returns a copy of the state of the given workspace
+func (b *MemoryBackend) ReadState(workspace string) (*statefile.File, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.states[workspace].DeepCopy(), nil
+}
+
+// WriteState stores a copy of the state of the given workspace, and adds it to
+// the history replacing any snapshot with the same serial
+func (b *MemoryBackend) WriteState(workspace string, sf *statefile.File) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.states[workspace] = sf.DeepCopy()
+
+	history := []memorySnapshot{}
+	for _, snapshot := range b.history[workspace] {
+		if snapshot.Serial != sf.Serial {
+			history = append(history, snapshot)
+		}
+	}
+	history = append(history, memorySnapshot{
+		StateSnapshot: StateSnapshot{Serial: sf.Serial, Lineage: sf.Lineage, Created: time.Now()},
+		sf:            sf.DeepCopy(),
+	})
+	sort.Slice(history, func(i, j int) bool { return history[i].Serial < history[j].Serial })
+
+	expired := map[uint64]bool{}
+	for _, snapshot := range b.Retention.expired(snapshotsOf(history)) {
+		expired[snapshot.Serial] = true
+	}
+	b.history[workspace] = history[:0]
+	for _, snapshot := range history {
+		if !expired[snapshot.Serial] {
+			b.history[workspace] = append(b.history[workspace], snapshot)
+		}
+	}
+
+	return nil
+}
+
+// History returns the snapshots kept of the state of the given workspace
+func (b *MemoryBackend) History(workspace string) ([]StateSnapshot, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return snapshotsOf(b.history[workspace]), nil
+}
+
+// ReadSnapshot returns a copy of the snapshot of the state of the given
+// workspace with the given serial
+func (b *MemoryBackend) ReadSnapshot(workspace string, serial uint64) (*statefile.File, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, snapshot := range b.history[workspace] {
+		if snapshot.Serial == serial {
+			return snapshot.sf.DeepCopy(), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: serial %d", ErrSnapshotNotFound, serial)
+}
+
+// Lock locks the state of the given workspace
+func (b *MemoryBackend) Lock(workspace string, info *LockInfo) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if lockInfo, ok := b.locks[workspace]; ok {
+		return "", &StateLockedError{Workspace: workspace, Info: lockInfo}
+	}
+	lockInfo := newLockInfo(info)
+	b.locks[workspace] = lockInfo
+	return lockInfo.ID, nil
+}
+
+// Unlock unlocks the state of the given workspace
+func (b *MemoryBackend) Unlock(workspace string, id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lockInfo, ok := b.locks[workspace]
+	if !ok {
+		return ErrStateNotLocked
+	}
+	if lockInfo.ID != id {
+		return &StateLockedError{Workspace: workspace, Info: lockInfo}
+	}
+	delete(b.locks, workspace)
+	return nil
+}
+
+// Workspaces returns the sorted list of workspaces with a state
+func (b *MemoryBackend) Workspaces() ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	workspaces := []string{DefaultWorkspace}
+	for workspace := range b.states {
+		if workspace != DefaultWorkspace {
+			workspaces = append(workspaces, workspace)
+		}
+	}
+	sort.Strings(workspaces[1:])
+	return workspaces, nil
+}
+
+// DeleteWorkspace deletes the state of the given workspace
+func (b *MemoryBackend) DeleteWorkspace(workspace string) error {
+	if workspace == DefaultWorkspace {
+		return ErrDefaultWorkspace
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if lockInfo, ok := b.locks[workspace]; ok {
+		return &StateLockedError{Workspace: workspace, Info: lockInfo}
+	}
+	delete(b.states, workspace)
+	delete(b.history, workspace)
+	return nil
+}
+
+func snapshotsOf(history []memorySnapshot) []StateSnapshot {
+	snapshots := make([]StateSnapshot, 0, len(history))
+	for _, snapshot := range history {
+		snapshots = append(snapshots, snapshot.StateSnapshot)
+	}
+	return snapshots
+}
@@ -0,0 +1,432 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/states/statefile"
+)
+
+// The SQL statements used by the SQLBackend. The table names are replaced by
+// the prefixed names and the `?` by the placeholders of the database.
+const (
+	sqlCreateStates = `CREATE TABLE IF NOT EXISTS {states} (
+	workspace VARCHAR(255) NOT NULL PRIMARY KEY,
+	lineage VARCHAR(255) NOT NULL,
+	serial BIGINT NOT NULL,
+	state TEXT NOT NULL
+)`
+	sqlCreateLocks = `CREATE TABLE IF NOT EXISTS {locks} (
+	workspace VARCHAR(255) NOT NULL PRIMARY KEY,
+	id VARCHAR(255) NOT NULL,
+	info TEXT NOT NULL
+)`
+	sqlCreateSnapshots = `CREATE TABLE IF NOT EXISTS {snapshots} (
+	workspace VARCHAR(255) NOT NULL,
+	serial BIGINT NOT NULL,
+	lineage VARCHAR(255) NOT NULL,
+	state TEXT NOT NULL,
+	created BIGINT NOT NULL,
+	PRIMARY KEY (workspace, serial)
+)`
+
+	sqlSelectState      = `SELECT state FROM {states} WHERE workspace = ?`
+	sqlSelectVersion    = `SELECT lineage, serial FROM {states} WHERE workspace = ? {for_update}`
+	sqlInsertState      = `INSERT INTO {states} (workspace, lineage, serial, state) VALUES (?, ?, ?, ?)`
+	sqlUpdateState      = `UPDATE {states} SET lineage = ?, serial = ?, state = ? WHERE workspace = ?`
+	sqlDeleteState      = `DELETE FROM {states} WHERE workspace = ?`
+	sqlSelectWorkspaces = `SELECT workspace FROM {states}`
+
+	sqlSelectLock = `SELECT info FROM {locks} WHERE workspace = ? {for_update}`
+	sqlInsertLock = `INSERT INTO {locks} (workspace, id, info) VALUES (?, ?, ?)`
+	sqlDeleteLock = `DELETE FROM {locks} WHERE workspace = ?`
+
+	sqlSelectSnapshots = `SELECT serial, lineage, created FROM {snapshots} WHERE workspace = ? ORDER BY serial`
+	sqlSelectSnapshot  = `SELECT state FROM {snapshots} WHERE workspace = ? AND serial = ?`
+	sqlInsertSnapshot  = `INSERT INTO {snapshots} (workspace, serial, lineage, state, created) VALUES (?, ?, ?, ?, ?)`
+	sqlDeleteSnapshot  = `DELETE FROM {snapshots} WHERE workspace = ? AND serial = ?`
+	sqlDeleteSnapshots = `DELETE FROM {snapshots} WHERE workspace = ?`
+)
+
+// SQLBackend is a StateBackend saving the states, the locks and the history of
+// the states, with the given retention policy, to a database/sql database. The
+// database driver is imported and opened by the caller.
+//
+// Every workspace has a row in the states table with its state, lineage and
+// serial, a row in the locks table while it's locked and a row in the
+// snapshots table for every state kept in the history. The tables are created
+// with CreateTables, or by the caller with the same columns, i.e. using a
+// bigger text type for the states in MySQL.
+//
+// The rows of a workspace are locked with SELECT ... FOR UPDATE inside a
+// transaction, so concurrent writers are serialized by the database, and the
+// lineage and serial of the stored state are verified in the same transaction
+// it's written.
+type SQLBackend struct {
+	DB *sql.DB
+	// TablePrefix is the prefix of the names of the tables, `terranova_` by
+	// default
+	TablePrefix string
+	// Placeholder returns the placeholder of the n-th parameter of a statement,
+	// starting at 1. If it's nil, `?` is used. Use PostgresPlaceholder for
+	// PostgreSQL.
+	Placeholder func(n int) string
+	// ForUpdate is the clause to lock the selected rows, `FOR UPDATE` by
+	// default. Set it empty for databases without row-level locks, like SQLite,
+	// where a transaction locks the entire database.
+	ForUpdate string
+	Retention RetentionPolicy
+}
+
+// NewSQLBackend creates a SQLBackend using the given database, the default
+// table prefix and retention policy
+func NewSQLBackend(db *sql.DB) *SQLBackend {
+	return &SQLBackend{
+		DB:          db,
+		TablePrefix: "terranova_",
+		ForUpdate:   "FOR UPDATE",
+		Retention:   DefaultRetentionPolicy,
+	}
+}
+
+// PostgresPlaceholder returns the PostgreSQL placeholder of the n-th parameter
+func PostgresPlaceholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+// CreateTables creates the tables of the backend if they don't exist
+func (b *SQLBackend) CreateTables() error {
+	for _, stmt := range []string{sqlCreateStates, sqlCreateLocks, sqlCreateSnapshots} {
+		if _, err := b.DB.Exec(b.query(stmt)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadState reads the state of the given workspace from the states table
+func (b *SQLBackend) ReadState(workspace string) (*statefile.File, error) {
+	var state string
+	err := b.DB.QueryRow(b.query(sqlSelectState), workspace).Scan(&state)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return readSQLState(state)
+}
+
+// WriteState saves the state of the given workspace to the states table, and
+// adds it to the history replacing any snapshot with the same serial. It
+// returns a *StateConflictError if the stored state has a different lineage or
+// a higher serial.
+func (b *SQLBackend) WriteState(workspace string, sf *statefile.File) error {
+	return b.CompareAndWriteState(workspace, sf, sf.Serial)
+}
+
+// CompareAndWriteState is like WriteState but the stored state can't have a
+// serial higher than the given one, the serial of the state read or saved by
+// the writer
+func (b *SQLBackend) CompareAndWriteState(workspace string, sf *statefile.File, serial uint64) error {
+	var buf bytes.Buffer
+	if err := statefile.Write(sf, &buf); err != nil {
+		return err
+	}
+	state := buf.String()
+
+	conflictErr := func(storedLineage string, storedSerial uint64) error {
+		return &StateConflictError{
+			Lineage:       sf.Lineage,
+			Serial:        serial,
+			StoredLineage: storedLineage,
+			StoredSerial:  storedSerial,
+		}
+	}
+
+	var inserting bool
+	err := b.transaction(func(tx *sql.Tx) error {
+		var storedLineage string
+		var storedSerial uint64
+		err := tx.QueryRow(b.query(sqlSelectVersion), workspace).Scan(&storedLineage, &storedSerial)
+		switch {
+		case err == sql.ErrNoRows:
+			inserting = true
+			_, err = tx.Exec(b.query(sqlInsertState), workspace, sf.Lineage, sf.Serial, state)
+		case err == nil:
+			if (len(storedLineage) != 0 && storedLineage != sf.Lineage) || storedSerial > serial {
+				return conflictErr(storedLineage, storedSerial)
+			}
+			_, err = tx.Exec(b.query(sqlUpdateState), sf.Lineage, sf.Serial, state, workspace)
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(b.query(sqlDeleteSnapshot), workspace, sf.Serial); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(b.query(sqlInsertSnapshot), workspace, sf.Serial, sf.Lineage, state, time.Now().UnixNano()); err != nil {
+			return err
+		}
+
+		snapshots, err := b.history(tx, workspace)
+		if err != nil {
+			return err
+		}
+		for _, snapshot := range b.Retention.expired(snapshots) {
+			if _, err := tx.Exec(b.query(sqlDeleteSnapshot), workspace, snapshot.Serial); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil || !inserting {
+		return err
+	}
+
+	// A concurrent writer may have inserted the row first, failing the insert
+	var storedLineage string
+	var storedSerial uint64
+	if b.DB.QueryRow(b.query(sqlSelectVersion), workspace).Scan(&storedLineage, &storedSerial) == nil {
+		return conflictErr(storedLineage, storedSerial)
+	}
+	return err
+}
+
+// History returns the snapshots in the history of the given workspace
+func (b *SQLBackend) History(workspace string) ([]StateSnapshot, error) {
+	return b.history(b.DB, workspace)
+}
+
+// ReadSnapshot reads the snapshot of the state of the given workspace with the
+// given serial from the snapshots table
+func (b *SQLBackend) ReadSnapshot(workspace string, serial uint64) (*statefile.File, error) {
+	var state string
+	err := b.DB.QueryRow(b.query(sqlSelectSnapshot), workspace, serial).Scan(&state)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: serial %d", ErrSnapshotNotFound, serial)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return readSQLState(state)
+}
+
+// Lock locks the state of the given workspace inserting a row in the locks
+// table with the lock information
+func (b *SQLBackend) Lock(workspace string, info *LockInfo) (string, error) {
+	lockInfo := newLockInfo(info)
+
+	err := b.transaction(func(tx *sql.Tx) error {
+		holder, err := b.lockInfo(tx, workspace)
+		if err == nil {
+			return &StateLockedError{Workspace: workspace, Info: holder}
+		}
+		if err != ErrStateNotLocked {
+			return err
+		}
+		_, err = tx.Exec(b.query(sqlInsertLock), workspace, lockInfo.ID, string(lockInfo.Marshal()))
+		return err
+	})
+	if err == nil {
+		return lockInfo.ID, nil
+	}
+
+	// A concurrent Lock may have inserted the row first, failing the insert
+	if _, ok := err.(*StateLockedError); !ok {
+		if holder, lockErr := b.lockInfo(b.DB, workspace); lockErr == nil {
+			return "", &StateLockedError{Workspace: workspace, Info: holder}
+		}
+	}
+	return "", err
+}
+
+// Unlock unlocks the state of the given workspace deleting its row in the
+// locks table
+func (b *SQLBackend) Unlock(workspace string, id string) error {
+	return b.transaction(func(tx *sql.Tx) error {
+		holder, err := b.lockInfo(tx, workspace)
+		if err != nil {
+			return err
+		}
+		if holder.ID != id {
+			return &StateLockedError{Workspace: workspace, Info: holder}
+		}
+		_, err = tx.Exec(b.query(sqlDeleteLock), workspace)
+		return err
+	})
+}
+
+// Workspaces returns the default workspace and the workspaces in the states
+// table
+func (b *SQLBackend) Workspaces() ([]string, error) {
+	rows, err := b.DB.Query(b.query(sqlSelectWorkspaces))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var others []string
+	for rows.Next() {
+		var workspace string
+		if err := rows.Scan(&workspace); err != nil {
+			return nil, err
+		}
+		if workspace != DefaultWorkspace {
+			others = append(others, workspace)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(others)
+
+	return append([]string{DefaultWorkspace}, others...), nil
+}
+
+// DeleteWorkspace deletes the state and the history of the given workspace
+func (b *SQLBackend) DeleteWorkspace(workspace string) error {
+	if workspace == DefaultWorkspace {
+		return ErrDefaultWorkspace
+	}
+
+	return b.transaction(func(tx *sql.Tx) error {
+		holder, err := b.lockInfo(tx, workspace)
+		if err == nil {
+			return &StateLockedError{Workspace: workspace, Info: holder}
+		}
+		if err != ErrStateNotLocked {
+			return err
+		}
+		if _, err := tx.Exec(b.query(sqlDeleteState), workspace); err != nil {
+			return err
+		}
+		_, err = tx.Exec(b.query(sqlDeleteSnapshots), workspace)
+		return err
+	})
+}
+
+// sqlQuerier is implemented by *sql.DB and *sql.Tx
+type sqlQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// history returns the snapshots in the history of the given workspace
+func (b *SQLBackend) history(q sqlQuerier, workspace string) ([]StateSnapshot, error) {
+	rows, err := q.Query(b.query(sqlSelectSnapshots), workspace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []StateSnapshot{}
+	for rows.Next() {
+		var snapshot StateSnapshot
+		var created int64
+		if err := rows.Scan(&snapshot.Serial, &snapshot.Lineage, &created); err != nil {
+			return nil, err
+		}
+		snapshot.Created = time.Unix(0, created)
+		snapshots = append(snapshots, snapshot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sortSnapshots(snapshots)
+
+	return snapshots, nil
+}
+
+// lockInfo reads the lock information of the given workspace, locking its row
+// in the locks table when used in a transaction
+func (b *SQLBackend) lockInfo(q sqlQuerier, workspace string) (*LockInfo, error) {
+	var info string
+	err := q.QueryRow(b.query(sqlSelectLock), workspace).Scan(&info)
+	if err == sql.ErrNoRows {
+		return nil, ErrStateNotLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lockInfo := &LockInfo{}
+	if err := json.Unmarshal([]byte(info), lockInfo); err != nil {
+		return nil, err
+	}
+	return lockInfo, nil
+}
+
+// transaction runs the given function in a transaction, committed if the
+// function succeeds or rolled back otherwise
+func (b *SQLBackend) transaction(fn func(tx *sql.Tx) error) error {
+	tx, err := b.DB.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// query returns the given statement with the prefixed table names, the row
+// locking clause and the placeholders of the database
+func (b *SQLBackend) query(stmt string) string {
+	stmt = strings.NewReplacer(
+		"{states}", b.TablePrefix+"states",
+		"{locks}", b.TablePrefix+"locks",
+		"{snapshots}", b.TablePrefix+"snapshots",
+		"{for_update}", b.ForUpdate,
+	).Replace(stmt)
+	stmt = strings.TrimSpace(stmt)
+
+	if b.Placeholder == nil {
+		return stmt
+	}
+	var query strings.Builder
+	n := 0
+	for _, r := range stmt {
+		if r == '?' {
+			n++
+			query.WriteString(b.Placeholder(n))
+			continue
+		}
+		query.WriteRune(r)
+	}
+	return query.String()
+}
+
+// readSQLState reads the state file stored in a text column
+func readSQLState(state string) (*statefile.File, error) {
+	sf, err := statefile.Read(strings.NewReader(state))
+	if err == statefile.ErrNoState {
+		return nil, nil
+	}
+	return sf, err
+}
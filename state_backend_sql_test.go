@@ -0,0 +1,400 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/terraform/states/statefile"
+)
+
+func TestSQLBackend(t *testing.T) {
+	testStateBackend(t, newSQLBackendForTest(t))
+
+	// A stored state with another lineage or a higher serial is not overwritten
+	backend := newSQLBackendForTest(t)
+	if err := backend.WriteState(DefaultWorkspace, statefile.New(testInstancesState("foo"), "test", 1)); err != nil {
+		t.Fatalf("SQLBackend.WriteState() error = %v", err)
+	}
+	var conflictErr *StateConflictError
+	for _, sf := range []*statefile.File{
+		statefile.New(testInstancesState("bar"), "test", 0),
+		statefile.New(testInstancesState("bar"), "other", 2),
+	} {
+		if err := backend.WriteState(DefaultWorkspace, sf); !errors.As(err, &conflictErr) {
+			t.Errorf("SQLBackend.WriteState(%s, %d) error = %v, want %T", sf.Lineage, sf.Serial, err, conflictErr)
+		}
+	}
+
+	// Concurrent writers of the same serial are serialized by the database, and
+	// only the first one saves the state
+	var wg sync.WaitGroup
+	written := make(chan string, 10)
+	ids := make(chan string, 10)
+	for i := 0; i < cap(ids); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("writer%d", i)
+			sf := statefile.New(testInstancesState(name), "test", 2)
+			err := backend.CompareAndWriteState(DefaultWorkspace, sf, 1)
+			var conflictErr *StateConflictError
+			switch {
+			case err == nil:
+				written <- name
+			case !errors.As(err, &conflictErr):
+				t.Errorf("SQLBackend.CompareAndWriteState() error = %v, want %T", err, conflictErr)
+			}
+
+			var lockedErr *StateLockedError
+			id, err := backend.Lock(DefaultWorkspace, &LockInfo{Operation: "test"})
+			if err != nil && !errors.As(err, &lockedErr) {
+				t.Errorf("SQLBackend.Lock() error = %v, want %T", err, lockedErr)
+			}
+			ids <- id
+		}(i)
+	}
+	wg.Wait()
+	close(written)
+	close(ids)
+
+	var writers []string
+	for name := range written {
+		writers = append(writers, name)
+	}
+	if len(writers) != 1 {
+		t.Fatalf("SQLBackend.CompareAndWriteState() saved the state %d times, want 1", len(writers))
+	}
+	if sf, err := backend.ReadState(DefaultWorkspace); err != nil || !statefile.StatesMarshalEqual(sf.State, testInstancesState(writers[0])) {
+		t.Errorf("SQLBackend.ReadState() = %v, %v, want the state of %s", sf, err, writers[0])
+	}
+
+	var locked []string
+	for id := range ids {
+		if len(id) != 0 {
+			locked = append(locked, id)
+		}
+	}
+	if len(locked) != 1 {
+		t.Errorf("SQLBackend.Lock() locked the state %d times, want 1", len(locked))
+	}
+}
+
+func TestPlatform_PersistStateTo_sql(t *testing.T) {
+	keys := NewKeyRing("key", bytes.Repeat([]byte{1}, 32))
+
+	tests := []struct {
+		name    string
+		backend StateBackend
+	}{
+		{"sql", newSQLBackendForTest(t)},
+		{"encrypted", NewEncryptedBackend(newSQLBackendForTest(t), keys)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newPlatformForTest(platformFields{State: testInstancesState("foo", "bar")}).PersistStateTo(tt.backend)
+			if err != nil {
+				t.Fatalf("Platform.PersistStateTo() error = %v", err)
+			}
+			other, err := NewPlatform("").PersistStateTo(tt.backend)
+			if err != nil {
+				t.Fatalf("Platform.PersistStateTo() error = %v", err)
+			}
+
+			if _, err := p.StateRm("test_instance.foo"); err != nil {
+				t.Fatalf("Platform.StateRm() error = %v", err)
+			}
+			var conflictErr *StateConflictError
+			if _, err := other.StateRm("test_instance.bar"); !errors.As(err, &conflictErr) {
+				t.Errorf("Platform.StateRm() of a stale state error = %v, want %T", err, conflictErr)
+			}
+			if sf, _ := tt.backend.ReadState(DefaultWorkspace); sf == nil || !statefile.StatesMarshalEqual(sf.State, p.State) {
+				t.Errorf("ReadState() = %v, want %v", sf, p.State)
+			}
+		})
+	}
+}
+
+func TestSQLBackend_query(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend *SQLBackend
+		stmt    string
+		want    string
+	}{
+		{"default", NewSQLBackend(nil), sqlSelectLock, "SELECT info FROM terranova_locks WHERE workspace = ? FOR UPDATE"},
+		{"postgres", &SQLBackend{TablePrefix: "tf_", Placeholder: PostgresPlaceholder, ForUpdate: "FOR UPDATE"}, sqlUpdateState, "UPDATE tf_states SET lineage = $1, serial = $2, state = $3 WHERE workspace = $4"},
+		{"no row locks", &SQLBackend{}, sqlSelectVersion, "SELECT lineage, serial FROM states WHERE workspace = ?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.backend.query(tt.stmt); got != tt.want {
+				t.Errorf("SQLBackend.query() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// newSQLBackendForTest returns a SQLBackend with its tables created in a new
+// database of the fake driver
+func newSQLBackendForTest(t *testing.T) *SQLBackend {
+	t.Helper()
+
+	db, err := sql.Open(testSQLDriverName, fmt.Sprintf("%s-%d", t.Name(), atomic.AddInt64(&testSQLDatabases, 1)))
+	if err != nil {
+		t.Fatalf("Failed to open the test database. %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	backend := NewSQLBackend(db)
+	if err := backend.CreateTables(); err != nil {
+		t.Fatalf("SQLBackend.CreateTables() error = %v", err)
+	}
+	return backend
+}
+
+const testSQLDriverName = "terranova_test"
+
+// testSQLDatabases is the number of databases opened by the tests, to give a
+// unique name to every database
+var testSQLDatabases int64
+
+func init() {
+	sql.Register(testSQLDriverName, &testSQLDriver{dbs: map[string]*testSQLDB{}})
+}
+
+// testSQLDriver is a fake database/sql driver keeping the tables of the
+// SQLBackend in memory. It only understands the statements of the SQLBackend,
+// and a transaction locks the entire database.
+type testSQLDriver struct {
+	mu  sync.Mutex
+	dbs map[string]*testSQLDB
+}
+
+func (d *testSQLDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	db, ok := d.dbs[name]
+	if !ok {
+		db = &testSQLDB{tables: map[string][][]driver.Value{}}
+		d.dbs[name] = db
+	}
+	return &testSQLConn{db: db}, nil
+}
+
+// testSQLDB is a database of the fake driver. The rows of every table are the
+// values of the columns in the order they are created.
+type testSQLDB struct {
+	mu     sync.Mutex
+	tables map[string][][]driver.Value
+}
+
+// exec runs the given statement, the database lock has to be held by the
+// caller
+func (db *testSQLDB) exec(query string, args []driver.Value) (*testSQLRows, error) {
+	b := NewSQLBackend(nil)
+	where := func(table string, columns ...int) [][]driver.Value {
+		var rows [][]driver.Value
+		for _, row := range db.tables[table] {
+			match := true
+			for i, column := range columns {
+				match = match && row[column] == args[i]
+			}
+			if match {
+				rows = append(rows, row)
+			}
+		}
+		return rows
+	}
+	remove := func(table string, columns ...int) {
+		rows := db.tables[table][:0]
+		for _, row := range db.tables[table] {
+			match := true
+			for i, column := range columns {
+				match = match && row[column] == args[i]
+			}
+			if !match {
+				rows = append(rows, row)
+			}
+		}
+		db.tables[table] = rows
+	}
+	insert := func(table string, keys int) error {
+		if len(where(table, firstColumns(keys)...)) != 0 {
+			return fmt.Errorf("duplicate key in table %s", table)
+		}
+		db.tables[table] = append(db.tables[table], args)
+		return nil
+	}
+	project := func(rows [][]driver.Value, columns ...int) *testSQLRows {
+		result := &testSQLRows{columns: len(columns)}
+		for _, row := range rows {
+			values := make([]driver.Value, 0, len(columns))
+			for _, column := range columns {
+				values = append(values, row[column])
+			}
+			result.rows = append(result.rows, values)
+		}
+		return result
+	}
+
+	switch query {
+	case b.query(sqlCreateStates), b.query(sqlCreateLocks), b.query(sqlCreateSnapshots):
+		return nil, nil
+	case b.query(sqlSelectState):
+		return project(where("states", 0), 3), nil
+	case b.query(sqlSelectVersion):
+		return project(where("states", 0), 1, 2), nil
+	case b.query(sqlInsertState):
+		return nil, insert("states", 1)
+	case b.query(sqlUpdateState):
+		for _, row := range db.tables["states"] {
+			if row[0] == args[3] {
+				row[1], row[2], row[3] = args[0], args[1], args[2]
+			}
+		}
+		return nil, nil
+	case b.query(sqlDeleteState):
+		remove("states", 0)
+		return nil, nil
+	case b.query(sqlSelectWorkspaces):
+		return project(db.tables["states"], 0), nil
+	case b.query(sqlSelectLock):
+		return project(where("locks", 0), 2), nil
+	case b.query(sqlInsertLock):
+		return nil, insert("locks", 1)
+	case b.query(sqlDeleteLock):
+		remove("locks", 0)
+		return nil, nil
+	case b.query(sqlSelectSnapshots):
+		rows := where("snapshots", 0)
+		sort.Slice(rows, func(i, j int) bool { return rows[i][1].(int64) < rows[j][1].(int64) })
+		return project(rows, 1, 2, 4), nil
+	case b.query(sqlSelectSnapshot):
+		return project(where("snapshots", 0, 1), 3), nil
+	case b.query(sqlInsertSnapshot):
+		return nil, insert("snapshots", 2)
+	case b.query(sqlDeleteSnapshot):
+		remove("snapshots", 0, 1)
+		return nil, nil
+	case b.query(sqlDeleteSnapshots):
+		remove("snapshots", 0)
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unexpected query %q", query)
+}
+
+// firstColumns returns the indexes of the first n columns
+func firstColumns(n int) []int {
+	columns := make([]int, n)
+	for i := range columns {
+		columns[i] = i
+	}
+	return columns
+}
+
+type testSQLConn struct {
+	db     *testSQLDB
+	backup map[string][][]driver.Value
+}
+
+func (c *testSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return &testSQLStmt{conn: c, query: query}, nil
+}
+
+func (c *testSQLConn) Close() error { return nil }
+
+func (c *testSQLConn) Begin() (driver.Tx, error) {
+	c.db.mu.Lock()
+	c.backup = map[string][][]driver.Value{}
+	for table, rows := range c.db.tables {
+		for _, row := range rows {
+			c.backup[table] = append(c.backup[table], append([]driver.Value{}, row...))
+		}
+	}
+	return c, nil
+}
+
+func (c *testSQLConn) Commit() error {
+	c.backup = nil
+	c.db.mu.Unlock()
+	return nil
+}
+
+func (c *testSQLConn) Rollback() error {
+	c.db.tables, c.backup = c.backup, nil
+	c.db.mu.Unlock()
+	return nil
+}
+
+type testSQLStmt struct {
+	conn  *testSQLConn
+	query string
+}
+
+func (s *testSQLStmt) Close() error  { return nil }
+func (s *testSQLStmt) NumInput() int { return strings.Count(s.query, "?") }
+
+func (s *testSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if _, err := s.Query(args); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (s *testSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if s.conn.backup == nil {
+		s.conn.db.mu.Lock()
+		defer s.conn.db.mu.Unlock()
+	}
+	rows, err := s.conn.db.exec(s.query, args)
+	if rows == nil {
+		rows = &testSQLRows{}
+	}
+	return rows, err
+}
+
+type testSQLRows struct {
+	columns int
+	rows    [][]driver.Value
+}
+
+func (r *testSQLRows) Columns() []string {
+	return make([]string, r.columns)
+}
+
+func (r *testSQLRows) Close() error { return nil }
+
+func (r *testSQLRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
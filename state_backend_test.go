@@ -0,0 +1,251 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/states/statefile"
+)
+
+func TestMemoryBackend(t *testing.T) {
+	testStateBackend(t, NewMemoryBackend())
+}
+
+func TestFileBackend(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", ".terranova_backend_test")
+	if err != nil {
+		t.Fatalf("Failed to create temporal directory. %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	filename := filepath.Join(tmpDir, "test.tfstate")
+	testStateBackend(t, NewFileBackend(filename))
+
+	// The state file is backed up the first time it's written
+	if err := ioutil.WriteFile(filename, []byte(testStateStr), 0644); err != nil {
+		t.Fatalf("fail to create state file %q. %s", filename, err)
+	}
+	backend := NewFileBackend(filename)
+	for serial := uint64(1); serial <= 2; serial++ {
+		if err := backend.WriteState(DefaultWorkspace, statefile.New(testInstancesState("foo"), "test", serial)); err != nil {
+			t.Fatalf("FileBackend.WriteState() error = %v", err)
+		}
+	}
+	if got, err := ioutil.ReadFile(filename + ".bkp"); err != nil || string(got) != testStateStr {
+		t.Errorf("FileBackend.WriteState() backup = %s, %v, want %s", got, err, testStateStr)
+	}
+	if got := readStateFileMeta(t, filename).Serial; got != 2 {
+		t.Errorf("FileBackend.WriteState() serial = %d, want 2", got)
+	}
+
+	// The history is not kept by default
+	if _, err := os.Stat(filename + ".history"); !os.IsNotExist(err) {
+		t.Errorf("FileBackend.WriteState() created the history directory, error = %v", err)
+	}
+
+	// The snapshots are identified by the serial and lineage in the file name
+	backend.KeepHistory = true
+	for _, lineage := range []string{"test", "other/lineage"} {
+		if err := backend.WriteState(DefaultWorkspace, statefile.New(testInstancesState("foo"), lineage, 3)); err != nil {
+			t.Fatalf("FileBackend.WriteState() error = %v", err)
+		}
+	}
+	history, err := backend.History(DefaultWorkspace)
+	if err != nil || len(history) != 1 || history[0].Serial != 3 || history[0].Lineage != "other/lineage" {
+		t.Errorf("FileBackend.History() = %+v, %v, want serial 3 with lineage \"other/lineage\"", history, err)
+	}
+	if sf, err := backend.ReadSnapshot(DefaultWorkspace, 3); err != nil || sf.Lineage != "other/lineage" {
+		t.Errorf("FileBackend.ReadSnapshot() = %+v, %v, want lineage \"other/lineage\"", sf, err)
+	}
+}
+
+func TestPlatform_PersistStateTo(t *testing.T) {
+	backend := NewMemoryBackend()
+
+	p, err := newPlatformForTest(platformFields{State: testInstancesState("foo")}).PersistStateTo(backend)
+	if err != nil {
+		t.Fatalf("Platform.PersistStateTo() error = %v", err)
+	}
+	if _, err := p.Taint("test_instance.foo"); err != nil {
+		t.Fatalf("Platform.Taint() error = %v", err)
+	}
+
+	sf, _ := backend.ReadState(DefaultWorkspace)
+	if sf == nil || sf.Lineage != p.lineage || sf.Serial != 1 {
+		t.Fatalf("Platform.PersistStateTo() saved %+v, want lineage %q and serial 1", sf, p.lineage)
+	}
+
+	other, err := NewPlatform("").PersistStateTo(backend)
+	if err != nil {
+		t.Fatalf("Platform.PersistStateTo() error = %v", err)
+	}
+	if !other.State.Equal(p.State) || other.lineage != p.lineage || other.serial != 1 {
+		t.Errorf("Platform.PersistStateTo() read %v (%s, %d), want %v (%s, 1)", other.State, other.lineage, other.serial, p.State, p.lineage)
+	}
+
+	// The state read is not saved again
+	failing := &failingBackend{MemoryBackend: backend, err: errors.New("backend error")}
+	if _, err := NewPlatform("").PersistStateTo(failing); err != nil {
+		t.Errorf("Platform.PersistStateTo() saved the state read, error = %v", err)
+	}
+	if history, _ := backend.History(DefaultWorkspace); len(history) != 2 {
+		t.Errorf("Platform.PersistStateTo() history = %+v, want 2 snapshots", history)
+	}
+}
+
+func TestPlatform_Apply_persistState(t *testing.T) {
+	backend := &failingBackend{MemoryBackend: NewMemoryBackend()}
+	p, err := NewPlatform("output \"foo\" {\n  value = \"bar\"\n}\n").PersistStateTo(backend)
+	if err != nil {
+		t.Fatalf("Platform.PersistStateTo() error = %v", err)
+	}
+
+	// The final state is saved even if no resource changed
+	if err := p.Apply(false); err != nil {
+		t.Fatalf("Platform.Apply() error = %v", err)
+	}
+	sf, _ := backend.ReadState(DefaultWorkspace)
+	if sf == nil || sf.Serial != 1 || sf.State.RootModule().OutputValues["foo"] == nil {
+		t.Fatalf("Platform.Apply() saved %+v, want the output value with serial 1", sf)
+	}
+
+	// An error saving the final state is returned
+	backend.err = errors.New("backend error")
+	if err := p.Apply(false); !errors.Is(err, backend.err) {
+		t.Errorf("Platform.Apply() error = %v, want %v", err, backend.err)
+	}
+}
+
+func TestPlatform_Apply_hooks(t *testing.T) {
+	backend := &failingBackend{MemoryBackend: NewMemoryBackend()}
+	p, err := newPlatformForTest(testsPlatformsFields["test instance"]).PersistStateTo(backend)
+	if err != nil {
+		t.Fatalf("Platform.PersistStateTo() error = %v", err)
+	}
+	hooks := len(p.Hooks)
+
+	// The hooks of an apply are not kept, so every apply saves the state the same
+	// number of times
+	var writes []int
+	for i := 0; i < 3; i++ {
+		backend.writes = 0
+		if err := p.Apply(false); err != nil {
+			t.Fatalf("Platform.Apply() error = %v", err)
+		}
+		writes = append(writes, backend.writes)
+		if len(p.Hooks) != hooks {
+			t.Errorf("Platform.Apply() hooks = %d, want %d", len(p.Hooks), hooks)
+		}
+	}
+	if writes[1] != writes[2] {
+		t.Errorf("Platform.Apply() saved the state %v times, want the same number of times", writes)
+	}
+}
+
+// failingBackend is a StateBackend counting the saved states and failing to
+// save the state with err, if set
+type failingBackend struct {
+	*MemoryBackend
+	err    error
+	writes int
+}
+
+func (b *failingBackend) WriteState(workspace string, sf *statefile.File) error {
+	if b.err != nil {
+		return b.err
+	}
+	b.writes++
+	return b.MemoryBackend.WriteState(workspace, sf)
+}
+
+// testStateBackend verifies the behavior of every StateBackend
+func testStateBackend(t *testing.T, backend StateBackend) {
+	t.Helper()
+
+	// States
+	if sf, err := backend.ReadState(DefaultWorkspace); sf != nil || err != nil {
+		t.Errorf("ReadState() of an empty backend = %v, %v, want nil", sf, err)
+	}
+	for _, workspace := range []string{DefaultWorkspace, "staging", "dev"} {
+		want := statefile.New(testInstancesState(workspace), "lineage-"+workspace, 3)
+		if err := backend.WriteState(workspace, want); err != nil {
+			t.Fatalf("WriteState(%q) error = %v", workspace, err)
+		}
+		got, err := backend.ReadState(workspace)
+		if err != nil {
+			t.Fatalf("ReadState(%q) error = %v", workspace, err)
+		}
+		if got == nil || got.Lineage != want.Lineage || got.Serial != want.Serial || !statefile.StatesMarshalEqual(got.State, want.State) {
+			t.Errorf("ReadState(%q) = %+v, want %+v", workspace, got, want)
+		}
+	}
+
+	// Workspaces
+	if got, err := backend.Workspaces(); err != nil || !reflect.DeepEqual(got, []string{DefaultWorkspace, "dev", "staging"}) {
+		t.Errorf("Workspaces() = %v, %v, want [default dev staging]", got, err)
+	}
+
+	// Locks
+	info := &LockInfo{Operation: "test"}
+	id, err := backend.Lock("staging", info)
+	if err != nil || len(id) == 0 {
+		t.Fatalf("Lock() = %q, %v, want a lock ID", id, err)
+	}
+	var lockedErr *StateLockedError
+	if _, err := backend.Lock("staging", info); !errors.As(err, &lockedErr) {
+		t.Errorf("Lock() of a locked state error = %v, want %T", err, lockedErr)
+	} else if lockedErr.Info == nil || lockedErr.Info.ID != id || lockedErr.Info.Operation != "test" {
+		t.Errorf("Lock() of a locked state info = %+v, want the lock with ID %q", lockedErr.Info, id)
+	}
+	if otherID, err := backend.Lock(DefaultWorkspace, info); err != nil {
+		t.Errorf("Lock() of other workspace error = %v", err)
+	} else if err := backend.Unlock(DefaultWorkspace, otherID); err != nil {
+		t.Errorf("Unlock() of other workspace error = %v", err)
+	}
+	if err := backend.Unlock("staging", "wrong"); !errors.As(err, &lockedErr) {
+		t.Errorf("Unlock() with a wrong ID error = %v, want %T", err, lockedErr)
+	}
+	if err := backend.DeleteWorkspace("staging"); !errors.As(err, &lockedErr) {
+		t.Errorf("DeleteWorkspace() of a locked workspace error = %v, want %T", err, lockedErr)
+	}
+	if err := backend.Unlock("staging", id); err != nil {
+		t.Errorf("Unlock() error = %v", err)
+	}
+	if err := backend.Unlock("staging", id); !errors.Is(err, ErrStateNotLocked) {
+		t.Errorf("Unlock() of an unlocked state error = %v, want %v", err, ErrStateNotLocked)
+	}
+
+	// Delete
+	if err := backend.DeleteWorkspace(DefaultWorkspace); !errors.Is(err, ErrDefaultWorkspace) {
+		t.Errorf("DeleteWorkspace() of the default workspace error = %v, want %v", err, ErrDefaultWorkspace)
+	}
+	if err := backend.DeleteWorkspace("staging"); err != nil {
+		t.Errorf("DeleteWorkspace() error = %v", err)
+	}
+	if sf, err := backend.ReadState("staging"); sf != nil || err != nil {
+		t.Errorf("ReadState() of a deleted workspace = %v, %v, want nil", sf, err)
+	}
+	if got, err := backend.Workspaces(); err != nil || !reflect.DeepEqual(got, []string{DefaultWorkspace, "dev"}) {
+		t.Errorf("Workspaces() = %v, %v, want [default dev]", got, err)
+	}
+}
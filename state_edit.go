@@ -0,0 +1,308 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states"
+)
+
+// StateRm removes from the state the given resource, resource instance or
+// module addresses, i.e. `aws_instance.server`, `aws_instance.server[0]` or
+// `module.db`. The real infrastructure is not destroyed, it's just not managed
+// anymore. If any address is not found, the state is not modified.
+func (p *Platform) StateRm(addresses ...string) (*Platform, error) {
+	targets := make([]addrs.Targetable, 0, len(addresses))
+	for _, addr := range addresses {
+		target, err := parseTarget(addr)
+		if err != nil {
+			return p, err
+		}
+		targets = append(targets, target)
+	}
+
+	return p, p.editState(func(state *State) error {
+		for _, target := range targets {
+			if err := removeFromState(state, target); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// StateMv moves a resource, resource instance or module in the state to a new
+// address, i.e. when the resource is renamed or moved into a module in the
+// code, so it's not destroyed and created again. It also moves instances
+// between `count` and `for_each` keys, i.e. from `aws_instance.server` to
+// `aws_instance.server[0]`.
+func (p *Platform) StateMv(from, to string) (*Platform, error) {
+	fromTarget, err := parseTarget(from)
+	if err != nil {
+		return p, err
+	}
+	toTarget, err := parseTarget(to)
+	if err != nil {
+		return p, err
+	}
+
+	return p, p.editState(func(state *State) error {
+		return moveInState(state, fromTarget, toTarget)
+	})
+}
+
+// StateReplaceProvider replaces the provider of every resource in the state
+// using the provider `from` with the provider `to`, i.e. when the code is
+// changed to use a forked or renamed provider. Both have to be legacy provider
+// addresses, and the new provider has to be added to the platform with
+// AddProvider.
+func (p *Platform) StateReplaceProvider(from, to addrs.Provider) (*Platform, error) {
+	for _, provider := range []addrs.Provider{from, to} {
+		if !isLegacyProvider(provider) {
+			return p, fmt.Errorf("%w: %s", ErrNotLegacyProvider, provider)
+		}
+	}
+	if _, ok := p.Providers[to]; !ok {
+		return p, fmt.Errorf("%w: %s", ErrProviderNotFound, to)
+	}
+
+	return p, p.editState(func(state *State) error {
+		var replaced bool
+		for _, ms := range state.Modules {
+			for _, rs := range ms.Resources {
+				if rs.ProviderConfig.ProviderConfig.Type == from {
+					rs.ProviderConfig.ProviderConfig.Type = to
+					replaced = true
+				}
+			}
+		}
+		if !replaced {
+			return fmt.Errorf("%w: %s", ErrProviderNotInState, from)
+		}
+		return nil
+	})
+}
+
+// isLegacyProvider returns true if the provider address has the legacy format,
+// the only one this version of Terraform can save in the state
+func isLegacyProvider(provider addrs.Provider) bool {
+	return provider.Namespace == "-"
+}
+
+// editState applies the given changes to a copy of the state. If there is no
+// error, the copy becomes the new state and it's saved with the state manager.
+func (p *Platform) editState(changeFn func(state *State) error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	state := states.NewState()
+	if p.State != nil {
+		state = p.State.DeepCopy()
+	}
+
+	if err := changeFn(state); err != nil {
+		return err
+	}
+
+	p.State = state
+	p.serial++
+
+	return p.persistState()
+}
+
+func removeFromState(state *State, target addrs.Targetable) error {
+	switch t := target.(type) {
+	case addrs.ModuleInstance:
+		if t.IsRoot() {
+			return fmt.Errorf("the root module cannot be removed")
+		}
+		modules := modulesIn(state, t)
+		if len(modules) == 0 {
+			return &ResourceNotFoundError{Addr: t.String()}
+		}
+		for _, ms := range modules {
+			state.RemoveModule(ms.Addr)
+		}
+
+	case addrs.AbsResource:
+		ms := state.Module(t.Module)
+		if ms == nil || ms.Resource(t.Resource) == nil {
+			return &ResourceNotFoundError{Addr: t.String()}
+		}
+		ms.RemoveResource(t.Resource)
+
+	case addrs.AbsResourceInstance:
+		ms := state.Module(t.Module)
+		if ms == nil || ms.ResourceInstance(t.Resource) == nil {
+			return &ResourceNotFoundError{Addr: t.String()}
+		}
+		ms.ForgetResourceInstanceAll(t.Resource)
+	}
+
+	return nil
+}
+
+func moveInState(state *State, from, to addrs.Targetable) error {
+	switch f := from.(type) {
+	case addrs.ModuleInstance:
+		if t, ok := to.(addrs.ModuleInstance); ok {
+			return moveModule(state, f, t)
+		}
+
+	case addrs.AbsResource:
+		switch t := to.(type) {
+		case addrs.ModuleInstance:
+			return moveResource(state, f, f.Resource.Absolute(t))
+		case addrs.AbsResource:
+			return moveResource(state, f, t)
+		case addrs.AbsResourceInstance:
+			return moveInstance(state, f.Instance(addrs.NoKey), t)
+		}
+
+	case addrs.AbsResourceInstance:
+		switch t := to.(type) {
+		case addrs.ModuleInstance:
+			return moveInstance(state, f, f.Resource.Absolute(t))
+		case addrs.AbsResource:
+			return moveInstance(state, f, t.Instance(addrs.NoKey))
+		case addrs.AbsResourceInstance:
+			return moveInstance(state, f, t)
+		}
+	}
+
+	return fmt.Errorf("cannot move %s to %s, a module can only be moved to another module", from, to)
+}
+
+// moveModule moves the module and all its child modules
+func moveModule(state *State, from, to addrs.ModuleInstance) error {
+	if from.IsRoot() || to.IsRoot() {
+		return fmt.Errorf("the root module cannot be moved")
+	}
+	if moduleContains(from, to) {
+		return fmt.Errorf("cannot move %s into itself", from)
+	}
+
+	modules := modulesIn(state, from)
+	if len(modules) == 0 {
+		return &ResourceNotFoundError{Addr: from.String()}
+	}
+
+	for _, ms := range modules {
+		newAddr := append(append(addrs.ModuleInstance{}, to...), ms.Addr[len(from):]...)
+		if state.Module(newAddr) != nil {
+			return fmt.Errorf("cannot move %s, %s already exists in the state", ms.Addr, newAddr)
+		}
+		state.RemoveModule(ms.Addr)
+		ms.Addr = newAddr
+		state.Modules[newAddr.String()] = ms
+	}
+
+	return nil
+}
+
+// moveResource moves the resource with all its instances
+func moveResource(state *State, from, to addrs.AbsResource) error {
+	if from.Resource.Mode != to.Resource.Mode || from.Resource.Type != to.Resource.Type {
+		return fmt.Errorf("cannot move %s to %s, they have different types", from, to)
+	}
+
+	src := state.Module(from.Module)
+	if src == nil || src.Resource(from.Resource) == nil {
+		return &ResourceNotFoundError{Addr: from.String()}
+	}
+	dst := state.EnsureModule(to.Module)
+	if dst.Resource(to.Resource) != nil {
+		return fmt.Errorf("cannot move %s, %s already exists in the state", from, to)
+	}
+
+	rs := src.Resource(from.Resource)
+	src.RemoveResource(from.Resource)
+	rs.Addr = to.Resource
+	dst.Resources[to.Resource.String()] = rs
+
+	return nil
+}
+
+// moveInstance moves a resource instance, the instance key defines if the
+// destination resource uses `count`, `for_each` or none of them
+func moveInstance(state *State, from, to addrs.AbsResourceInstance) error {
+	fromRes, toRes := from.Resource.Resource, to.Resource.Resource
+	if fromRes.Mode != toRes.Mode || fromRes.Type != toRes.Type {
+		return fmt.Errorf("cannot move %s to %s, they have different types", from, to)
+	}
+
+	src := state.Module(from.Module)
+	if src == nil || src.ResourceInstance(from.Resource) == nil {
+		return &ResourceNotFoundError{Addr: from.String()}
+	}
+	ri := src.ResourceInstance(from.Resource)
+	provider := src.Resource(fromRes).ProviderConfig
+
+	dst := state.EnsureModule(to.Module)
+	if dst.ResourceInstance(to.Resource) != nil {
+		return fmt.Errorf("cannot move %s, %s already exists in the state", from, to)
+	}
+
+	src.ForgetResourceInstanceAll(from.Resource)
+
+	eachMode := eachModeForKey(to.Resource.Key)
+	if rs := dst.Resource(toRes); rs != nil && rs.EachMode != eachMode && len(rs.Instances) != 0 {
+		return fmt.Errorf("cannot move %s to %s, the instances of %s use a different key type", from, to, to.ContainingResource())
+	}
+	dst.SetResourceMeta(toRes, eachMode, provider)
+	dst.Resource(toRes).Instances[to.Resource.Key] = ri
+
+	return nil
+}
+
+// modulesIn returns the given module and all its child modules in the state
+func modulesIn(state *State, addr addrs.ModuleInstance) []*states.Module {
+	var modules []*states.Module
+	for _, ms := range state.Modules {
+		if moduleContains(addr, ms.Addr) {
+			modules = append(modules, ms)
+		}
+	}
+	return modules
+}
+
+// moduleContains returns true if the module is the other module or its
+// ancestor
+func moduleContains(m, other addrs.ModuleInstance) bool {
+	return len(other) >= len(m) && other[:len(m)].Equal(m)
+}
+
+func eachModeForKey(key addrs.InstanceKey) states.EachMode {
+	switch key.(type) {
+	case addrs.IntKey:
+		return states.EachList
+	case addrs.StringKey:
+		return states.EachMap
+	}
+	return states.NoEach
+}
+
+// parseTarget parses the given module, resource or resource instance address
+func parseTarget(addr string) (addrs.Targetable, error) {
+	target, diags := addrs.ParseTargetStr(addr)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("invalid address %q. %w", addr, newDiagnosticsError(diags, nil))
+	}
+	return target.Subject, nil
+}
@@ -0,0 +1,305 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states"
+)
+
+func TestPlatform_StateRm(t *testing.T) {
+	tests := []struct {
+		name         string
+		addrs        []string
+		want         []string
+		wantNotFound bool
+		wantErr      bool
+	}{
+		{"instance", []string{"module.child.test_instance.baz[0]"}, []string{
+			"data.test_ds.bar",
+			"module.child.test_instance.baz[1]",
+			"test_instance.bar",
+			"test_instance.foo",
+		}, false, false},
+		{"resource", []string{"test_instance.foo"}, []string{
+			"data.test_ds.bar",
+			"module.child.test_instance.baz[0]",
+			"module.child.test_instance.baz[1]",
+			"test_instance.bar",
+		}, false, false},
+		{"module", []string{"module.child"}, []string{
+			"data.test_ds.bar",
+			"test_instance.bar",
+			"test_instance.foo",
+		}, false, false},
+		{"many addresses", []string{"test_instance.foo", "data.test_ds.bar"}, []string{
+			"module.child.test_instance.baz[0]",
+			"module.child.test_instance.baz[1]",
+			"test_instance.bar",
+		}, false, false},
+		{"not found", []string{"test_instance.foo", "test_instance.qux"}, nil, true, true},
+		{"invalid address", []string{"test_instance"}, nil, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPlatformForTest(platformFields{State: testInspectState()})
+			filename := persistStateForTest(t, p)
+
+			_, err := p.StateRm(tt.addrs...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Platform.StateRm() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var notFoundErr *ResourceNotFoundError
+			if got := errors.As(err, &notFoundErr); got != tt.wantNotFound {
+				t.Errorf("Platform.StateRm() error = %v, want ResourceNotFoundError %v", err, tt.wantNotFound)
+			}
+			if err != nil {
+				if got, want := p.StateList(), testInspectStateList(); !reflect.DeepEqual(got, want) {
+					t.Errorf("Platform.StateRm() modified the state on error, got %v, want %v", got, want)
+				}
+				return
+			}
+
+			assertStateList(t, p, filename, tt.want)
+		})
+	}
+}
+
+func TestPlatform_StateMv(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		want    []string
+		wantErr bool
+	}{
+		{"rename resource", "test_instance.foo", "test_instance.qux", []string{
+			"data.test_ds.bar",
+			"module.child.test_instance.baz[0]",
+			"module.child.test_instance.baz[1]",
+			"test_instance.bar",
+			"test_instance.qux",
+		}, false},
+		{"resource into module", "test_instance.foo", "module.child", []string{
+			"data.test_ds.bar",
+			"module.child.test_instance.baz[0]",
+			"module.child.test_instance.baz[1]",
+			"module.child.test_instance.foo",
+			"test_instance.bar",
+		}, false},
+		{"resource to new module", "test_instance.foo", "module.other.test_instance.foo", []string{
+			"data.test_ds.bar",
+			"module.child.test_instance.baz[0]",
+			"module.child.test_instance.baz[1]",
+			"module.other.test_instance.foo",
+			"test_instance.bar",
+		}, false},
+		{"resource to count", "test_instance.foo", "test_instance.foo[0]", []string{
+			"data.test_ds.bar",
+			"module.child.test_instance.baz[0]",
+			"module.child.test_instance.baz[1]",
+			"test_instance.bar",
+			"test_instance.foo[0]",
+		}, false},
+		{"resource to for_each", "test_instance.foo", `test_instance.foo["a"]`, []string{
+			"data.test_ds.bar",
+			"module.child.test_instance.baz[0]",
+			"module.child.test_instance.baz[1]",
+			"test_instance.bar",
+			`test_instance.foo["a"]`,
+		}, false},
+		{"instance to other key", "module.child.test_instance.baz[1]", "module.child.test_instance.baz[2]", []string{
+			"data.test_ds.bar",
+			"module.child.test_instance.baz[0]",
+			"module.child.test_instance.baz[2]",
+			"test_instance.bar",
+			"test_instance.foo",
+		}, false},
+		{"rename module", "module.child", "module.other", []string{
+			"data.test_ds.bar",
+			"module.other.test_instance.baz[0]",
+			"module.other.test_instance.baz[1]",
+			"test_instance.bar",
+			"test_instance.foo",
+		}, false},
+		{"module into module", "module.child", "module.parent.module.child", []string{
+			"data.test_ds.bar",
+			"module.parent.module.child.test_instance.baz[0]",
+			"module.parent.module.child.test_instance.baz[1]",
+			"test_instance.bar",
+			"test_instance.foo",
+		}, false},
+		{"mixed keys", "test_instance.foo", `module.child.test_instance.baz["a"]`, nil, true},
+		{"destination exists", "test_instance.foo", "test_instance.bar", nil, true},
+		{"different types", "test_instance.foo", "data.test_ds.foo", nil, true},
+		{"module into itself", "module.child", "module.child.module.grandchild", nil, true},
+		{"module to resource", "module.child", "test_instance.qux", nil, true},
+		{"not found", "test_instance.qux", "test_instance.quux", nil, true},
+		{"invalid address", "test_instance.foo", "test_instance", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPlatformForTest(platformFields{State: testInspectState()})
+			filename := persistStateForTest(t, p)
+
+			_, err := p.StateMv(tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Platform.StateMv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if got, want := p.StateList(), testInspectStateList(); !reflect.DeepEqual(got, want) {
+					t.Errorf("Platform.StateMv() modified the state on error, got %v, want %v", got, want)
+				}
+				return
+			}
+
+			assertStateList(t, p, filename, tt.want)
+		})
+	}
+
+	p := newPlatformForTest(platformFields{State: testInspectState()})
+	if _, err := p.StateMv("module.child.test_instance.baz[1]", "test_instance.baz"); err != nil {
+		t.Fatalf("Platform.StateMv() error = %v", err)
+	}
+	ri, err := p.StateShow("test_instance.baz")
+	if err != nil {
+		t.Fatalf("Platform.StateShow() error = %v", err)
+	}
+	if !ri.Tainted || ri.Attributes["id"] != "baz-1" {
+		t.Errorf("Platform.StateMv() moved instance = %+v, want the tainted baz-1", ri)
+	}
+	if rs := p.State.Resource(testInstanceAddr("baz").ContainingResource()); rs.EachMode != states.NoEach {
+		t.Errorf("Platform.StateMv() each mode = %v, want %v", rs.EachMode, states.NoEach)
+	}
+}
+
+func TestPlatform_StateReplaceProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		want    map[string]string
+		wantErr error
+	}{
+		{"replace", "test", "fork", map[string]string{
+			"data.test_ds.bar":                  "provider.fork",
+			"module.child.test_instance.baz[0]": "provider.fork",
+			"module.child.test_instance.baz[1]": "provider.fork",
+			"null_resource.qux":                 "provider.null",
+			"test_instance.bar":                 "provider.fork",
+			"test_instance.foo":                 "provider.fork",
+		}, nil},
+		{"not in state", "aws", "fork", nil, ErrProviderNotInState},
+		{"unknown provider", "test", "unknown", nil, ErrProviderNotFound},
+		{"not legacy from", "hashicorp/test", "fork", nil, ErrNotLegacyProvider},
+		{"not legacy to", "test", "hashicorp/fork", nil, ErrNotLegacyProvider},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := testInspectState()
+			state.RootModule().SetResourceInstanceCurrent(
+				addrs.Resource{Mode: addrs.ManagedResourceMode, Type: "null_resource", Name: "qux"}.Instance(addrs.NoKey),
+				&states.ResourceInstanceObjectSrc{Status: states.ObjectReady, AttrsJSON: []byte(`{"id":"qux"}`)},
+				addrs.ProviderConfig{Type: addrs.NewLegacyProvider("null")}.Absolute(addrs.RootModuleInstance),
+			)
+			p := newPlatformForTest(platformFields{State: state})
+			p.Providers[addrs.NewLegacyProvider("fork")] = p.Providers[addrs.NewLegacyProvider("null")]
+			p.Providers[addrs.NewDefaultProvider("fork")] = p.Providers[addrs.NewLegacyProvider("null")]
+			filename := persistStateForTest(t, p)
+
+			_, err := p.StateReplaceProvider(testProviderAddr(tt.from), testProviderAddr(tt.to))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Platform.StateReplaceProvider() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			persistedState, err := readStateFile(filename)
+			if err != nil {
+				t.Fatalf("fail to read state file %q. %s", filename, err)
+			}
+			persisted := newPlatformForTest(platformFields{State: persistedState})
+			for addr, want := range tt.want {
+				for _, platform := range []*Platform{p, persisted} {
+					ri, err := platform.StateShow(addr)
+					if err != nil {
+						t.Fatalf("Platform.StateShow(%q) error = %v", addr, err)
+					}
+					if ri.Provider != want {
+						t.Errorf("provider of %s = %s, want %s", addr, ri.Provider, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+// testProviderAddr returns the address of the given provider, a legacy
+// provider unless it has a namespace, i.e. `hashicorp/test`
+func testProviderAddr(provider string) addrs.Provider {
+	if i := strings.Index(provider, "/"); i != -1 {
+		return addrs.NewDefaultProvider(provider[i+1:])
+	}
+	return addrs.NewLegacyProvider(provider)
+}
+
+// persistStateForTest saves the platform state in a temporal file which is
+// removed when the test ends
+func persistStateForTest(t *testing.T, p *Platform) string {
+	tmpDir, err := ioutil.TempDir("", ".terranova_state_edit_test")
+	if err != nil {
+		t.Fatalf("Failed to create temporal directory. %s", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+
+	filename := filepath.Join(tmpDir, "test.tfstate")
+	if _, err := p.PersistStateToFile(filename); err != nil {
+		t.Fatalf("Platform.PersistStateToFile() error = %v", err)
+	}
+	return filename
+}
+
+// assertStateList verifies the resources in the platform state and in the
+// persisted state file
+func assertStateList(t *testing.T, p *Platform, filename string, want []string) {
+	t.Helper()
+	if got := p.StateList(); !reflect.DeepEqual(got, want) {
+		t.Errorf("state resources = %v, want %v", got, want)
+	}
+
+	state, err := readStateFile(filename)
+	if err != nil {
+		t.Fatalf("fail to read state file %q. %s", filename, err)
+	}
+	persisted := newPlatformForTest(platformFields{State: state})
+	if got := persisted.StateList(); !reflect.DeepEqual(got, want) {
+		t.Errorf("persisted state resources = %v, want %v", got, want)
+	}
+}
+
+func testInspectStateList() []string {
+	return newPlatformForTest(platformFields{State: testInspectState()}).StateList()
+}
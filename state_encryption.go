@@ -0,0 +1,335 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// encryptedStateOutput is the name of the output value with the encrypted
+// state. The encrypted state is saved as the only output value of an empty
+// state, so it keeps the lineage and serial of the original state.
+const encryptedStateOutput = "terranova_encrypted_state"
+
+// encryptionAlgorithm is the algorithm used to encrypt the state
+const encryptionAlgorithm = "AES-GCM"
+
+// KeyProvider provides the keys to encrypt and decrypt the state. Every key has
+// an ID, saved with the encrypted state to know which key decrypts it. The keys
+// have to be 16, 24 or 32 bytes long to use AES-128, AES-192 or AES-256.
+type KeyProvider interface {
+	// CurrentKey returns the ID and the key to encrypt the state
+	CurrentKey() (string, []byte, error)
+	// Key returns the key with the given ID to decrypt the state
+	Key(id string) ([]byte, error)
+}
+
+// KeyRing is a KeyProvider with a set of keys, the current key is used to
+// encrypt and any of them to decrypt. To rotate the key use Rotate, the states
+// encrypted with the previous keys can still be decrypted and they are
+// encrypted with the new key the next time they are saved.
+type KeyRing struct {
+	mu      sync.RWMutex
+	current string
+	keys    map[string][]byte
+}
+
+// NewKeyRing creates a KeyRing with the given key as the current key
+func NewKeyRing(id string, key []byte) *KeyRing {
+	return (&KeyRing{keys: map[string][]byte{}}).Rotate(id, key)
+}
+
+// Rotate adds the given key to the key ring and makes it the current key
+func (r *KeyRing) Rotate(id string, key []byte) *KeyRing {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.keys[id] = append([]byte(nil), key...)
+	r.current = id
+	return r
+}
+
+// CurrentKey returns the ID and the current key
+func (r *KeyRing) CurrentKey() (string, []byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.current, r.keys[r.current], nil
+}
+
+// Key returns the key with the given ID
+func (r *KeyRing) Key(id string) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrKeyNotFound, id)
+	}
+	return key, nil
+}
+
+// EncryptState makes the platform encrypt the state with the keys of the given
+// key provider every time it's written, with WriteState or to a state backend,
+// and decrypt it when it's read. The state is only decrypted in memory. States
+// that are not encrypted can still be read, so they are encrypted the next time
+// they are saved. A FileBackend doesn't back up a state file that is not
+// encrypted when it's replaced by an encrypted state, but the snapshots in the
+// state history saved before remain not encrypted.
+func (p *Platform) EncryptState(keys KeyProvider) *Platform {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.keys = keys
+	if p.backend != nil {
+		if encrypted, ok := p.backend.(*EncryptedBackend); ok {
+			encrypted.Keys = keys
+		} else {
+			p.backend = NewEncryptedBackend(p.backend, keys)
+		}
+	}
+	return p
+}
+
+// EncryptedBackend is a StateBackend encrypting the states saved to another
+// state backend. The lineage and serial of the states are not encrypted. States
+// that are not encrypted can still be read.
+type EncryptedBackend struct {
+	Backend StateBackend
+	Keys    KeyProvider
+}
+
+// NewEncryptedBackend creates an EncryptedBackend saving the states to the given
+// backend encrypted with the keys of the given key provider
+func NewEncryptedBackend(backend StateBackend, keys KeyProvider) *EncryptedBackend {
+	return &EncryptedBackend{
+		Backend: backend,
+		Keys:    keys,
+	}
+}
+
+// ReadState reads and decrypts the state of the given workspace
+func (b *EncryptedBackend) ReadState(workspace string) (*statefile.File, error) {
+	sf, err := b.Backend.ReadState(workspace)
+	if err != nil {
+		return nil, err
+	}
+	return decryptStateFile(sf, b.Keys)
+}
+
+// WriteState encrypts and writes the state of the given workspace
+func (b *EncryptedBackend) WriteState(workspace string, sf *statefile.File) error {
+	encrypted, err := encryptStateFile(sf, b.Keys)
+	if err != nil {
+		return err
+	}
+	return b.Backend.WriteState(workspace, encrypted)
+}
+
+// CompareAndWriteState encrypts the state and stores it if the stored state
+// has the same lineage and a serial not higher than the given one, when the
+// wrapped backend is an AtomicStateBackend. Otherwise it's like WriteState.
+func (b *EncryptedBackend) CompareAndWriteState(workspace string, sf *statefile.File, serial uint64) error {
+	atomic, ok := b.Backend.(AtomicStateBackend)
+	if !ok {
+		return b.WriteState(workspace, sf)
+	}
+	encrypted, err := encryptStateFile(sf, b.Keys)
+	if err != nil {
+		return err
+	}
+	return atomic.CompareAndWriteState(workspace, encrypted, serial)
+}
+
+// Lock locks the state of the given workspace
+func (b *EncryptedBackend) Lock(workspace string, info *LockInfo) (string, error) {
+	return b.Backend.Lock(workspace, info)
+}
+
+// Unlock unlocks the state of the given workspace
+func (b *EncryptedBackend) Unlock(workspace string, id string) error {
+	return b.Backend.Unlock(workspace, id)
+}
+
+// Workspaces returns the workspaces with a state
+func (b *EncryptedBackend) Workspaces() ([]string, error) {
+	return b.Backend.Workspaces()
+}
+
+// DeleteWorkspace deletes the state of the given workspace
+func (b *EncryptedBackend) DeleteWorkspace(workspace string) error {
+	return b.Backend.DeleteWorkspace(workspace)
+}
+
+// History returns the snapshots kept of the state of the given workspace, if
+// the encrypted backend keeps the state history
+func (b *EncryptedBackend) History(workspace string) ([]StateSnapshot, error) {
+	history, ok := b.Backend.(HistoryBackend)
+	if !ok {
+		return nil, ErrHistoryNotSupported
+	}
+	return history.History(workspace)
+}
+
+// ReadSnapshot reads and decrypts the snapshot of the state of the given
+// workspace with the given serial, if the encrypted backend keeps the state
+// history
+func (b *EncryptedBackend) ReadSnapshot(workspace string, serial uint64) (*statefile.File, error) {
+	history, ok := b.Backend.(HistoryBackend)
+	if !ok {
+		return nil, ErrHistoryNotSupported
+	}
+	sf, err := history.ReadSnapshot(workspace, serial)
+	if err != nil {
+		return nil, err
+	}
+	return decryptStateFile(sf, b.Keys)
+}
+
+// RotateKey encrypts again the state of the given workspace with the current
+// key, so the previous key is not required anymore to decrypt it
+func (b *EncryptedBackend) RotateKey(workspace string) error {
+	sf, err := b.ReadState(workspace)
+	if err != nil || sf == nil {
+		return err
+	}
+	return b.WriteState(workspace, sf)
+}
+
+// encryptStateFile returns a state file, with the same lineage and serial of
+// the given state file, which only content is the given state file encrypted
+func encryptStateFile(sf *statefile.File, keys KeyProvider) (*statefile.File, error) {
+	var plaintext bytes.Buffer
+	if err := statefile.Write(sf, &plaintext); err != nil {
+		return nil, err
+	}
+
+	id, key, err := keys.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	data := gcm.Seal(nonce, nonce, plaintext.Bytes(), encryptionData(sf))
+
+	state := states.NewState()
+	state.RootModule().SetOutputValue(encryptedStateOutput, cty.ObjectVal(map[string]cty.Value{
+		"algorithm": cty.StringVal(encryptionAlgorithm),
+		"key_id":    cty.StringVal(id),
+		"data":      cty.StringVal(base64.StdEncoding.EncodeToString(data)),
+	}), true)
+
+	return &statefile.File{
+		TerraformVersion: sf.TerraformVersion,
+		Lineage:          sf.Lineage,
+		Serial:           sf.Serial,
+		State:            state,
+	}, nil
+}
+
+// decryptStateFile returns the state file encrypted in the given state file.
+// If the state file is not encrypted it's returned as is.
+func decryptStateFile(sf *statefile.File, keys KeyProvider) (*statefile.File, error) {
+	if !isEncryptedStateFile(sf) {
+		return sf, nil
+	}
+	if keys == nil {
+		return nil, ErrStateEncrypted
+	}
+
+	output := sf.State.RootModule().OutputValues[encryptedStateOutput]
+	if output == nil || !output.Value.IsWhollyKnown() || output.Value.IsNull() || !output.Value.Type().IsObjectType() {
+		return nil, fmt.Errorf("failed to decrypt the state, unknown encrypted state format")
+	}
+	attrs := map[string]string{}
+	for _, name := range []string{"algorithm", "key_id", "data"} {
+		if !output.Value.Type().HasAttribute(name) {
+			return nil, fmt.Errorf("failed to decrypt the state, unknown encrypted state format")
+		}
+		attr := output.Value.GetAttr(name)
+		if attr.IsNull() || attr.Type() != cty.String {
+			return nil, fmt.Errorf("failed to decrypt the state, the %s of the encrypted state is not a string", name)
+		}
+		attrs[name] = attr.AsString()
+	}
+	if attrs["algorithm"] != encryptionAlgorithm {
+		return nil, fmt.Errorf("failed to decrypt the state, unknown encryption algorithm")
+	}
+
+	key, err := keys.Key(attrs["key_id"])
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(attrs["data"])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt the state. %w", err)
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("failed to decrypt the state, the encrypted data is too short")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], encryptionData(sf))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt the state. %w", err)
+	}
+
+	return statefile.Read(bytes.NewReader(plaintext))
+}
+
+// isEncryptedStateFile returns true if the given state file has an encrypted
+// state
+func isEncryptedStateFile(sf *statefile.File) bool {
+	if sf == nil || sf.State == nil || sf.State.RootModule() == nil {
+		return false
+	}
+	_, ok := sf.State.RootModule().OutputValues[encryptedStateOutput]
+	return ok
+}
+
+// encryptionData returns the additional data authenticated with the encrypted
+// state, so the encrypted state can't be used with another lineage or serial
+func encryptionData(sf *statefile.File) []byte {
+	return []byte(fmt.Sprintf("%s/%d", sf.Lineage, sf.Serial))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state encryption key. %w", err)
+	}
+	return cipher.NewGCM(block)
+}
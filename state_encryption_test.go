@@ -0,0 +1,249 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestEncryptedBackend(t *testing.T) {
+	key1, key2 := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 16)
+	keys := NewKeyRing("key1", key1)
+
+	inner := NewMemoryBackend()
+	backend := NewEncryptedBackend(inner, keys)
+
+	// Not encrypted states can be read
+	plain := statefile.New(testInstancesState("foo"), "test", 1)
+	inner.WriteState(DefaultWorkspace, plain)
+	if got, err := backend.ReadState(DefaultWorkspace); err != nil || !statefile.StatesMarshalEqual(got.State, plain.State) {
+		t.Errorf("EncryptedBackend.ReadState() of a not encrypted state = %v, %v, want %v", got, err, plain)
+	}
+
+	// The state is encrypted but the lineage and serial are not
+	want := statefile.New(testInstancesState("foo"), "test", 2)
+	if err := backend.WriteState(DefaultWorkspace, want); err != nil {
+		t.Fatalf("EncryptedBackend.WriteState() error = %v", err)
+	}
+	stored, _ := inner.ReadState(DefaultWorkspace)
+	if stored.Lineage != "test" || stored.Serial != 2 {
+		t.Errorf("EncryptedBackend.WriteState() stored lineage and serial = %q, %d, want \"test\", 2", stored.Lineage, stored.Serial)
+	}
+	if content := stateFileContent(t, stored); strings.Contains(content, "test_instance") || !strings.Contains(content, `"key_id": "key1"`) {
+		t.Errorf("EncryptedBackend.WriteState() stored a not encrypted state: %s", content)
+	}
+	assertEncryptedState(t, backend, want)
+
+	// After a key rotation, the states encrypted with the previous key can be read
+	keys.Rotate("key2", key2)
+	assertEncryptedState(t, backend, want)
+	if err := backend.RotateKey(DefaultWorkspace); err != nil {
+		t.Fatalf("EncryptedBackend.RotateKey() error = %v", err)
+	}
+	assertEncryptedState(t, NewEncryptedBackend(inner, NewKeyRing("key2", key2)), want)
+
+	// Wrong or unknown keys
+	if _, err := NewEncryptedBackend(inner, NewKeyRing("key1", key1)).ReadState(DefaultWorkspace); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("EncryptedBackend.ReadState() with unknown key error = %v, want %v", err, ErrKeyNotFound)
+	}
+	if _, err := NewEncryptedBackend(inner, NewKeyRing("key2", key1[:16])).ReadState(DefaultWorkspace); err == nil {
+		t.Errorf("EncryptedBackend.ReadState() with a wrong key expected an error")
+	}
+	if err := NewEncryptedBackend(inner, NewKeyRing("key3", []byte("short"))).WriteState(DefaultWorkspace, want); err == nil {
+		t.Errorf("EncryptedBackend.WriteState() with an invalid key expected an error")
+	}
+
+	// The encrypted state can't be used with other serial
+	stored, _ = inner.ReadState(DefaultWorkspace)
+	stored.Serial = 3
+	inner.WriteState(DefaultWorkspace, stored)
+	if _, err := backend.ReadState(DefaultWorkspace); err == nil {
+		t.Errorf("EncryptedBackend.ReadState() of a modified serial expected an error")
+	}
+}
+
+func TestDecryptStateFile_malformed(t *testing.T) {
+	keys := NewKeyRing("key", bytes.Repeat([]byte{1}, 32))
+	valid := map[string]cty.Value{
+		"algorithm": cty.StringVal(encryptionAlgorithm),
+		"key_id":    cty.StringVal("key"),
+		"data":      cty.StringVal("bm90IGVuY3J5cHRlZA=="),
+	}
+	with := func(name string, value cty.Value) cty.Value {
+		attrs := map[string]cty.Value{}
+		for k, v := range valid {
+			if k != name {
+				attrs[k] = v
+			}
+		}
+		if value != cty.NilVal {
+			attrs[name] = value
+		}
+		return cty.ObjectVal(attrs)
+	}
+
+	tests := []struct {
+		name  string
+		value cty.Value
+	}{
+		{"not an object", cty.StringVal("encrypted")},
+		{"null object", cty.NullVal(cty.Object(map[string]cty.Type{"data": cty.String}))},
+		{"unknown object", cty.UnknownVal(cty.Object(map[string]cty.Type{"data": cty.String}))},
+		{"no algorithm", with("algorithm", cty.NilVal)},
+		{"no key_id", with("key_id", cty.NilVal)},
+		{"no data", with("data", cty.NilVal)},
+		{"unknown algorithm", with("algorithm", cty.StringVal("rot13"))},
+		{"number algorithm", with("algorithm", cty.NumberIntVal(1))},
+		{"null key_id", with("key_id", cty.NullVal(cty.String))},
+		{"unknown key_id", with("key_id", cty.UnknownVal(cty.String))},
+		{"list key_id", with("key_id", cty.ListVal([]cty.Value{cty.StringVal("key")}))},
+		{"null data", with("data", cty.NullVal(cty.String))},
+		{"unknown data", with("data", cty.UnknownVal(cty.String))},
+		{"number data", with("data", cty.NumberIntVal(1))},
+		{"invalid data", with("data", cty.StringVal("not base64"))},
+		{"not encrypted data", cty.ObjectVal(valid)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := states.NewState()
+			state.RootModule().SetOutputValue(encryptedStateOutput, tt.value, true)
+			sf := statefile.New(state, "test", 1)
+			if !isEncryptedStateFile(sf) {
+				t.Fatalf("isEncryptedStateFile() = false, want true")
+			}
+			if got, err := decryptStateFile(sf, keys); err == nil {
+				t.Errorf("decryptStateFile() = %v, expected an error", got)
+			}
+		})
+	}
+}
+
+func TestPlatform_EncryptState(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", ".terranova_encryption_test")
+	if err != nil {
+		t.Fatalf("Failed to create temporal directory. %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	keys := NewKeyRing("key", bytes.Repeat([]byte{1}, 32))
+
+	// Write and read encrypted state files
+	filename := filepath.Join(tmpDir, "write.tfstate")
+	p := newPlatformForTest(platformFields{State: testInstancesState("foo")}).EncryptState(keys)
+	if _, err := p.WriteStateToFile(filename); err != nil {
+		t.Fatalf("Platform.WriteStateToFile() error = %v", err)
+	}
+	if content, _ := ioutil.ReadFile(filename); bytes.Contains(content, []byte("test_instance")) {
+		t.Errorf("Platform.WriteStateToFile() wrote a not encrypted state: %s", content)
+	}
+	got, err := NewPlatform("").EncryptState(keys).ReadStateFromFile(filename)
+	if err != nil {
+		t.Fatalf("Platform.ReadStateFromFile() error = %v", err)
+	}
+	if !statefile.StatesMarshalEqual(got.State, p.State) || got.lineage != p.lineage {
+		t.Errorf("Platform.ReadStateFromFile() = %v, want %v", got.State, p.State)
+	}
+	if _, err := NewPlatform("").ReadStateFromFile(filename); !errors.Is(err, ErrStateEncrypted) {
+		t.Errorf("Platform.ReadStateFromFile() without keys error = %v, want %v", err, ErrStateEncrypted)
+	}
+
+	// Persisted states are encrypted
+	filename = filepath.Join(tmpDir, "persist.tfstate")
+	p, err = newPlatformForTest(platformFields{State: testInstancesState("foo")}).EncryptState(keys).PersistStateToFile(filename)
+	if err != nil {
+		t.Fatalf("Platform.PersistStateToFile() error = %v", err)
+	}
+	if _, err := p.Taint("test_instance.foo"); err != nil {
+		t.Fatalf("Platform.Taint() error = %v", err)
+	}
+	if content, _ := ioutil.ReadFile(filename); bytes.Contains(content, []byte("test_instance")) {
+		t.Errorf("Platform.Taint() saved a not encrypted state: %s", content)
+	}
+	if sf := readStateFileMeta(t, filename); sf.Lineage != p.lineage || sf.Serial != 1 {
+		t.Errorf("persisted lineage and serial = %q, %d, want %q, 1", sf.Lineage, sf.Serial, p.lineage)
+	}
+	got, err = NewPlatform("").EncryptState(keys).PersistStateToFile(filename)
+	if err != nil {
+		t.Fatalf("Platform.PersistStateToFile() error = %v", err)
+	}
+	if ri, err := got.StateShow("test_instance.foo"); err != nil || !ri.Tainted {
+		t.Errorf("Platform.StateShow() = %+v, %v, want a tainted instance", ri, err)
+	}
+	if _, err := NewPlatform("").PersistStateToFile(filename); !errors.Is(err, ErrStateEncrypted) {
+		t.Errorf("Platform.PersistStateToFile() without keys error = %v, want %v", err, ErrStateEncrypted)
+	}
+
+	// A state file not encrypted is not kept in a backup when it's encrypted
+	filename = filepath.Join(tmpDir, "plain.tfstate")
+	if _, err := newPlatformForTest(platformFields{State: testInstancesState("foo")}).WriteStateToFile(filename); err != nil {
+		t.Fatalf("Platform.WriteStateToFile() error = %v", err)
+	}
+	p, err = NewPlatform("").EncryptState(keys).PersistStateTo(NewFileBackend(filename))
+	if err != nil {
+		t.Fatalf("Platform.PersistStateTo() error = %v", err)
+	}
+	if _, err := p.Taint("test_instance.foo"); err != nil {
+		t.Fatalf("Platform.Taint() error = %v", err)
+	}
+	if content, _ := ioutil.ReadFile(filename); bytes.Contains(content, []byte("test_instance")) {
+		t.Errorf("Platform.Taint() saved a not encrypted state: %s", content)
+	}
+	if _, err := os.Stat(filename + ".bkp"); !os.IsNotExist(err) {
+		t.Errorf("FileBackend kept a backup of the not encrypted state, error = %v", err)
+	}
+
+	// An encrypted state file is kept in a backup
+	p, err = NewPlatform("").EncryptState(keys).PersistStateTo(NewFileBackend(filename))
+	if err != nil {
+		t.Fatalf("Platform.PersistStateTo() error = %v", err)
+	}
+	if _, err := p.Untaint("test_instance.foo"); err != nil {
+		t.Fatalf("Platform.Untaint() error = %v", err)
+	}
+	if content, err := ioutil.ReadFile(filename + ".bkp"); err != nil || bytes.Contains(content, []byte("test_instance")) {
+		t.Errorf("FileBackend backup = %s, %v, want an encrypted state", content, err)
+	}
+}
+
+func assertEncryptedState(t *testing.T, backend StateBackend, want *statefile.File) {
+	t.Helper()
+	got, err := backend.ReadState(DefaultWorkspace)
+	if err != nil {
+		t.Fatalf("EncryptedBackend.ReadState() error = %v", err)
+	}
+	if got.Lineage != want.Lineage || got.Serial != want.Serial || !statefile.StatesMarshalEqual(got.State, want.State) {
+		t.Errorf("EncryptedBackend.ReadState() = %+v, want %+v", got, want)
+	}
+}
+
+func stateFileContent(t *testing.T, sf *statefile.File) string {
+	var content bytes.Buffer
+	if err := statefile.Write(sf, &content); err != nil {
+		t.Fatalf("fail to write the state file. %s", err)
+	}
+	return content.String()
+}
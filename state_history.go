@@ -0,0 +1,146 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/terraform/states/statefile"
+)
+
+// HistoryBackend is a StateBackend keeping the previous snapshots of the state
+type HistoryBackend interface {
+	StateBackend
+	// History returns the snapshots kept of the state of the given workspace,
+	// sorted by serial
+	History(workspace string) ([]StateSnapshot, error)
+	// ReadSnapshot returns the snapshot of the state of the given workspace with
+	// the given serial
+	ReadSnapshot(workspace string, serial uint64) (*statefile.File, error)
+}
+
+// StateSnapshot is a saved state, identified by its serial
+type StateSnapshot struct {
+	Serial  uint64
+	Lineage string
+	Created time.Time
+}
+
+// RetentionPolicy defines which snapshots are kept in the state history. The
+// latest snapshot is always kept.
+type RetentionPolicy struct {
+	// MaxSnapshots is the maximum number of snapshots to keep, zero keeps all of
+	// them
+	MaxSnapshots int
+	// MaxAge is the maximum age of the snapshots to keep, zero keeps all of them
+	MaxAge time.Duration
+}
+
+// DefaultRetentionPolicy keeps the last 10 snapshots
+var DefaultRetentionPolicy = RetentionPolicy{MaxSnapshots: 10}
+
+// expired returns the snapshots to remove from the given snapshots, sorted by
+// serial
+func (r RetentionPolicy) expired(snapshots []StateSnapshot) []StateSnapshot {
+	var expired []StateSnapshot
+	for i, snapshot := range snapshots {
+		if i == len(snapshots)-1 {
+			break
+		}
+		tooMany := r.MaxSnapshots > 0 && len(snapshots)-i > r.MaxSnapshots
+		tooOld := r.MaxAge > 0 && time.Since(snapshot.Created) > r.MaxAge
+		if tooMany || tooOld {
+			expired = append(expired, snapshot)
+		}
+	}
+	return expired
+}
+
+// StateHistory returns the snapshots of the state kept by the state backend,
+// sorted by serial
+func (p *Platform) StateHistory() ([]StateSnapshot, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	history, err := p.historyBackend()
+	if err != nil {
+		return nil, err
+	}
+	return history.History(p.workspace)
+}
+
+// RollbackState restores the state snapshot with the given serial from the
+// state history. The restored state is saved as a new serial, so it's also
+// possible to go back to the state replaced by the rollback.
+func (p *Platform) RollbackState(serial uint64) (*Platform, error) {
+	return p, p.rollbackState(serial)
+}
+
+func (p *Platform) rollbackState(serial uint64) (err error) {
+	unlock, err := p.lockState(context.Background(), "rollback")
+	if err != nil {
+		return err
+	}
+	defer unlock(&err)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	history, err := p.historyBackend()
+	if err != nil {
+		return err
+	}
+	sf, err := history.ReadSnapshot(p.workspace, serial)
+	if err != nil {
+		return err
+	}
+	if sf.Lineage != p.lineage {
+		return &StateConflictError{
+			Lineage:       p.lineage,
+			Serial:        p.storedSerial,
+			StoredLineage: sf.Lineage,
+			StoredSerial:  sf.Serial,
+		}
+	}
+
+	p.State = sf.State
+	p.serial++
+
+	return p.writeState(p.State)
+}
+
+// historyBackend returns the state backend if it keeps the state history. The
+// platform lock has to be held by the caller.
+func (p *Platform) historyBackend() (HistoryBackend, error) {
+	if p.backend == nil {
+		return nil, ErrNoStateBackend
+	}
+	history, ok := p.backend.(HistoryBackend)
+	if !ok {
+		return nil, ErrHistoryNotSupported
+	}
+	return history, nil
+}
+
+// sortSnapshots sorts the given snapshots by serial
+func sortSnapshots(snapshots []StateSnapshot) {
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Serial < snapshots[j].Serial
+	})
+}
@@ -0,0 +1,211 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+)
+
+func TestRetentionPolicy_expired(t *testing.T) {
+	now := time.Now()
+	snapshots := []StateSnapshot{
+		{Serial: 1, Created: now.Add(-3 * time.Hour)},
+		{Serial: 2, Created: now.Add(-2 * time.Hour)},
+		{Serial: 3, Created: now.Add(-time.Hour)},
+		{Serial: 4, Created: now.Add(-time.Hour)},
+	}
+	tests := []struct {
+		name      string
+		retention RetentionPolicy
+		want      []uint64
+	}{
+		{"keep all", RetentionPolicy{}, nil},
+		{"max snapshots", RetentionPolicy{MaxSnapshots: 2}, []uint64{1, 2}},
+		{"max age", RetentionPolicy{MaxAge: 90 * time.Minute}, []uint64{1, 2}},
+		{"max snapshots and age", RetentionPolicy{MaxSnapshots: 3, MaxAge: 150 * time.Minute}, []uint64{1}},
+		{"keep latest", RetentionPolicy{MaxAge: time.Minute}, []uint64{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []uint64
+			for _, snapshot := range tt.retention.expired(snapshots) {
+				got = append(got, snapshot.Serial)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RetentionPolicy.expired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryBackend(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", ".terranova_history_test")
+	if err != nil {
+		t.Fatalf("Failed to create temporal directory. %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fileBackend := NewFileBackend(filepath.Join(tmpDir, "test.tfstate"))
+	fileBackend.KeepHistory = true
+	fileBackend.Retention = RetentionPolicy{MaxSnapshots: 2}
+	memoryBackend := NewMemoryBackend()
+	memoryBackend.Retention = RetentionPolicy{MaxSnapshots: 2}
+	sqlBackend := newSQLBackendForTest(t)
+	sqlBackend.Retention = RetentionPolicy{MaxSnapshots: 2}
+	keys := NewKeyRing("key", bytes.Repeat([]byte{1}, 32))
+
+	tests := []struct {
+		name    string
+		backend HistoryBackend
+	}{
+		{"file", fileBackend},
+		{"memory", memoryBackend},
+		{"sql", sqlBackend},
+		{"encrypted", NewEncryptedBackend(NewMemoryBackend(), keys)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, workspace := range []string{DefaultWorkspace, "staging"} {
+				for _, sf := range []*statefile.File{
+					statefile.New(testInstancesState("foo"), "test", 1),
+					statefile.New(testInstancesState("foo"), "test", 2),
+					statefile.New(testInstancesState("foo", "bar"), "test", 2),
+					statefile.New(testInstancesState("bar"), "test", 3),
+				} {
+					if err := tt.backend.WriteState(workspace, sf); err != nil {
+						t.Fatalf("WriteState() error = %v", err)
+					}
+				}
+			}
+
+			history, err := tt.backend.History(DefaultWorkspace)
+			if err != nil {
+				t.Fatalf("History() error = %v", err)
+			}
+			var got []uint64
+			for _, snapshot := range history {
+				got = append(got, snapshot.Serial)
+				if snapshot.Lineage != "test" || snapshot.Created.IsZero() {
+					t.Errorf("History() snapshot = %+v, want lineage and creation time", snapshot)
+				}
+			}
+			want := []uint64{2, 3}
+			if tt.name == "encrypted" {
+				want = []uint64{1, 2, 3}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("History() serials = %v, want %v", got, want)
+			}
+
+			sf, err := tt.backend.ReadSnapshot(DefaultWorkspace, 2)
+			if err != nil {
+				t.Fatalf("ReadSnapshot() error = %v", err)
+			}
+			if want := testInstancesState("foo", "bar"); !statefile.StatesMarshalEqual(sf.State, want) {
+				t.Errorf("ReadSnapshot() = %v, want %v", sf.State, want)
+			}
+			if _, err := tt.backend.ReadSnapshot(DefaultWorkspace, 7); !errors.Is(err, ErrSnapshotNotFound) {
+				t.Errorf("ReadSnapshot() error = %v, want %v", err, ErrSnapshotNotFound)
+			}
+
+			if err := tt.backend.DeleteWorkspace("staging"); err != nil {
+				t.Fatalf("DeleteWorkspace() error = %v", err)
+			}
+			if history, err := tt.backend.History("staging"); err != nil || len(history) != 0 {
+				t.Errorf("History() of a deleted workspace = %v, %v, want no snapshots", history, err)
+			}
+		})
+	}
+}
+
+func TestPlatform_RollbackState(t *testing.T) {
+	p, err := newPlatformForTest(platformFields{State: testInstancesState("foo")}).PersistStateTo(NewMemoryBackend())
+	if err != nil {
+		t.Fatalf("Platform.PersistStateTo() error = %v", err)
+	}
+	if _, err := p.Taint("test_instance.foo"); err != nil {
+		t.Fatalf("Platform.Taint() error = %v", err)
+	}
+	if _, err := p.StateRm("test_instance.foo"); err != nil {
+		t.Fatalf("Platform.StateRm() error = %v", err)
+	}
+
+	assertHistory(t, p, []uint64{0, 1, 2})
+
+	if _, err := p.RollbackState(1); err != nil {
+		t.Fatalf("Platform.RollbackState() error = %v", err)
+	}
+	if ri, err := p.StateShow("test_instance.foo"); err != nil || !ri.Tainted {
+		t.Errorf("Platform.RollbackState() instance = %+v, %v, want the tainted instance", ri, err)
+	}
+	if p.serial != 3 {
+		t.Errorf("Platform.RollbackState() serial = %d, want 3", p.serial)
+	}
+	assertHistory(t, p, []uint64{0, 1, 2, 3})
+
+	if _, err := p.RollbackState(9); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("Platform.RollbackState() error = %v, want %v", err, ErrSnapshotNotFound)
+	}
+	if _, err := NewPlatform("").StateHistory(); !errors.Is(err, ErrNoStateBackend) {
+		t.Errorf("Platform.StateHistory() error = %v, want %v", err, ErrNoStateBackend)
+	}
+	noHistory, err := NewPlatform("").PersistStateTo(struct{ StateBackend }{NewMemoryBackend()})
+	if err != nil {
+		t.Fatalf("Platform.PersistStateTo() error = %v", err)
+	}
+	if _, err := noHistory.RollbackState(0); !errors.Is(err, ErrHistoryNotSupported) {
+		t.Errorf("Platform.RollbackState() error = %v, want %v", err, ErrHistoryNotSupported)
+	}
+
+	// A snapshot of another lineage is not restored
+	other := NewMemoryBackend()
+	other.WriteState(DefaultWorkspace, statefile.New(states.NewState(), "other", 5))
+	p, err = NewPlatform("").PersistStateTo(other)
+	if err != nil {
+		t.Fatalf("Platform.PersistStateTo() error = %v", err)
+	}
+	p.lineage = "new"
+	var conflictErr *StateConflictError
+	if _, err := p.RollbackState(5); !errors.As(err, &conflictErr) {
+		t.Errorf("Platform.RollbackState() error = %v, want %T", err, conflictErr)
+	}
+}
+
+func assertHistory(t *testing.T, p *Platform, want []uint64) {
+	t.Helper()
+	history, err := p.StateHistory()
+	if err != nil {
+		t.Fatalf("Platform.StateHistory() error = %v", err)
+	}
+	var got []uint64
+	for _, snapshot := range history {
+		got = append(got, snapshot.Serial)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Platform.StateHistory() serials = %v, want %v", got, want)
+	}
+}
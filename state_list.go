@@ -0,0 +1,134 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states"
+)
+
+// ResourceInstance is a resource instance in the state with its attributes
+// decoded from JSON
+type ResourceInstance struct {
+	Addr       string
+	Module     string
+	Mode       string
+	Type       string
+	Name       string
+	Provider   string
+	Tainted    bool
+	Attributes map[string]interface{}
+}
+
+// StateList returns the address of every resource instance in the state, in
+// all the modules, sorted by address. The filters are resource or module
+// addresses, i.e. `aws_instance.server` or `module.db`, to list only the
+// instances they contain. Invalid filters don't match any instance.
+func (p *Platform) StateList(filter ...string) []string {
+	var targets []addrs.Targetable
+	for _, f := range filter {
+		target, diags := addrs.ParseTargetStr(f)
+		if diags.HasErrors() {
+			continue
+		}
+		targets = append(targets, target.Subject)
+	}
+	if len(filter) != 0 && len(targets) == 0 {
+		return []string{}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	list := []string{}
+	for _, addr := range allResourceInstances(p.State) {
+		if len(targets) != 0 && !targetsContain(targets, addr) {
+			continue
+		}
+		list = append(list, addr.String())
+	}
+
+	return list
+}
+
+// StateShow returns the resource instance in the given address, i.e.
+// `aws_instance.server[0]` or `module.db.aws_db_instance.main`
+func (p *Platform) StateShow(addr string) (*ResourceInstance, error) {
+	instAddr, err := parseResourceInstance(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.State == nil {
+		return nil, &ResourceNotFoundError{Addr: instAddr.String()}
+	}
+	ri := p.State.ResourceInstance(instAddr)
+	if ri == nil || ri.Current == nil {
+		return nil, &ResourceNotFoundError{Addr: instAddr.String()}
+	}
+	rs := p.State.Resource(instAddr.ContainingResource())
+
+	attrs, err := attributesMap(ri.Current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the attributes of %s. %s", instAddr, err)
+	}
+
+	mode := "managed"
+	if instAddr.Resource.Resource.Mode == addrs.DataResourceMode {
+		mode = "data"
+	}
+
+	return &ResourceInstance{
+		Addr:       instAddr.String(),
+		Module:     instAddr.Module.String(),
+		Mode:       mode,
+		Type:       instAddr.Resource.Resource.Type,
+		Name:       instAddr.Resource.Resource.Name,
+		Provider:   rs.ProviderConfig.String(),
+		Tainted:    ri.Current.Status == states.ObjectTainted,
+		Attributes: attrs,
+	}, nil
+}
+
+// allResourceInstances returns the address of every managed and data resource
+// instance in the state, sorted by address
+func allResourceInstances(state *State) []addrs.AbsResourceInstance {
+	instances := append(
+		resourceInstances(state, addrs.ManagedResourceMode),
+		resourceInstances(state, addrs.DataResourceMode)...,
+	)
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].String() < instances[j].String()
+	})
+	return instances
+}
+
+// targetsContain returns true if any of the targets contains the address
+func targetsContain(targets []addrs.Targetable, addr addrs.AbsResourceInstance) bool {
+	for _, target := range targets {
+		if target.TargetContains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states"
+)
+
+func TestPlatform_StateList(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter []string
+		want   []string
+	}{
+		{"all", nil, []string{
+			"data.test_ds.bar",
+			"module.child.test_instance.baz[0]",
+			"module.child.test_instance.baz[1]",
+			"test_instance.bar",
+			"test_instance.foo",
+		}},
+		{"resource", []string{"test_instance.foo"}, []string{"test_instance.foo"}},
+		{"module", []string{"module.child"}, []string{
+			"module.child.test_instance.baz[0]",
+			"module.child.test_instance.baz[1]",
+		}},
+		{"instance", []string{"module.child.test_instance.baz[1]"}, []string{"module.child.test_instance.baz[1]"}},
+		{"many filters", []string{"test_instance.bar", "data.test_ds.bar"}, []string{"data.test_ds.bar", "test_instance.bar"}},
+		{"not found", []string{"test_instance.qux"}, []string{}},
+		{"invalid filter", []string{"test_instance"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPlatformForTest(platformFields{State: testInspectState()})
+			if got := p.StateList(tt.filter...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Platform.StateList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlatform_StateShow(t *testing.T) {
+	tests := []struct {
+		name    string
+		addr    string
+		want    *ResourceInstance
+		wantErr bool
+	}{
+		{"root resource", "test_instance.foo", &ResourceInstance{
+			Addr:       "test_instance.foo",
+			Mode:       "managed",
+			Type:       "test_instance",
+			Name:       "foo",
+			Provider:   "provider.test",
+			Attributes: map[string]interface{}{"id": "foo", "ami": "bar"},
+		}, false},
+		{"module resource", "module.child.test_instance.baz[1]", &ResourceInstance{
+			Addr:       "module.child.test_instance.baz[1]",
+			Module:     "module.child",
+			Mode:       "managed",
+			Type:       "test_instance",
+			Name:       "baz",
+			Provider:   "provider.test",
+			Tainted:    true,
+			Attributes: map[string]interface{}{"id": "baz-1", "ami": "bar", "count": float64(1)},
+		}, false},
+		{"data resource", "data.test_ds.bar", &ResourceInstance{
+			Addr:       "data.test_ds.bar",
+			Mode:       "data",
+			Type:       "test_ds",
+			Name:       "bar",
+			Provider:   "provider.test",
+			Attributes: map[string]interface{}{"filter": "foo"},
+		}, false},
+		{"not found", "test_instance.qux", nil, true},
+		{"invalid address", "test_instance", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPlatformForTest(platformFields{State: testInspectState()})
+			got, err := p.StateShow(tt.addr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Platform.StateShow() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Platform.StateShow() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	var notFoundErr *ResourceNotFoundError
+	if _, err := NewPlatform("").StateShow("test_instance.foo"); !errors.As(err, &notFoundErr) {
+		t.Errorf("Platform.StateShow() error = %v, want %T", err, notFoundErr)
+	}
+}
+
+// testInspectState creates a state with resources in the root and child module,
+// counted resources and a data source
+func testInspectState() *State {
+	state := testInstancesState("foo", "bar")
+	provider := addrs.ProviderConfig{Type: addrs.NewLegacyProvider("test")}.Absolute(addrs.RootModuleInstance)
+
+	child := state.EnsureModule(addrs.RootModuleInstance.Child("child", addrs.NoKey))
+	baz := addrs.Resource{Mode: addrs.ManagedResourceMode, Type: "test_instance", Name: "baz"}
+	child.SetResourceInstanceCurrent(baz.Instance(addrs.IntKey(0)), &states.ResourceInstanceObjectSrc{
+		Status:    states.ObjectReady,
+		AttrsJSON: []byte(`{"id":"baz-0","ami":"bar","count":0}`),
+	}, provider)
+	child.SetResourceInstanceCurrent(baz.Instance(addrs.IntKey(1)), &states.ResourceInstanceObjectSrc{
+		Status:    states.ObjectTainted,
+		AttrsJSON: []byte(`{"id":"baz-1","ami":"bar","count":1}`),
+	}, provider)
+
+	ds := addrs.Resource{Mode: addrs.DataResourceMode, Type: "test_ds", Name: "bar"}
+	state.RootModule().SetResourceInstanceCurrent(ds.Instance(addrs.NoKey), &states.ResourceInstanceObjectSrc{
+		Status:    states.ObjectReady,
+		AttrsJSON: []byte(`{"filter":"foo"}`),
+	}, provider)
+
+	return state
+}
@@ -0,0 +1,149 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform/states/statemgr"
+)
+
+// lockRetryDelay is the initial delay between attempts to lock the state while
+// it's locked by someone else, it's doubled on every attempt up to maxLockRetryDelay
+var (
+	lockRetryDelay    = time.Second
+	maxLockRetryDelay = 16 * time.Second
+)
+
+// LockTimeout sets how long to wait for the state lock when it's locked by
+// someone else. By default the operation fails immediately if the state is
+// locked.
+func (p *Platform) LockTimeout(timeout time.Duration) *Platform {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.lockTimeout = timeout
+	return p
+}
+
+// StaleLockTimeout sets how old a state lock has to be to consider it stale,
+// i.e. left by a process that crashed. A stale lock is removed, with a
+// warning, when the platform needs to lock the state. By default, no lock is
+// considered stale.
+func (p *Platform) StaleLockTimeout(timeout time.Duration) *Platform {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.staleLockTimeout = timeout
+	return p
+}
+
+// ForceUnlock removes the lock of the state with the given lock ID, i.e. the
+// ID in the *StateLockedError returned when the state is locked by a process
+// that won't unlock it. A lock that can't be read, like a lock file left empty
+// by a process that crashed, has an empty ID.
+func (p *Platform) ForceUnlock(id string) error {
+	p.mu.Lock()
+	backend, workspace := p.backend, p.workspace
+	p.mu.Unlock()
+
+	if backend == nil {
+		return ErrNoStateBackend
+	}
+	return backend.Unlock(workspace, id)
+}
+
+// lockState locks the state in the backend, if there is one, for the given
+// operation. Returns the function to unlock the state, which sets the given
+// error if the unlock fails and there is no other error.
+func (p *Platform) lockState(ctx context.Context, operation string) (func(err *error), error) {
+	p.mu.Lock()
+	backend, workspace := p.backend, p.workspace
+	timeout, staleTimeout := p.lockTimeout, p.staleLockTimeout
+	p.mu.Unlock()
+
+	if backend == nil {
+		return func(*error) {}, nil
+	}
+
+	info := statemgr.NewLockInfo()
+	info.Operation = operation
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	} else {
+		// Do not wait for the lock
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		cancel()
+	}
+
+	delay := lockRetryDelay
+	for {
+		id, err := backend.Lock(workspace, info)
+		if err == nil {
+			return unlockFunc(backend, workspace, id), nil
+		}
+
+		var lockedErr *StateLockedError
+		if !errors.As(err, &lockedErr) {
+			return nil, err
+		}
+		if isStaleLock(lockedErr.Info, staleTimeout) {
+			if err := backend.Unlock(workspace, lockedErr.Info.ID); err == nil {
+				p.addWarningDiagnostics(Diagnostics{{
+					Severity: SeverityWarning,
+					Summary:  "Stale state lock removed",
+					Detail:   fmt.Sprintf("The state lock created at %s was considered stale and removed. Lock Info:\n%s", lockedErr.Info.Created, lockedErr.Info),
+				}})
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+			if delay *= 2; delay > maxLockRetryDelay {
+				delay = maxLockRetryDelay
+			}
+		}
+	}
+}
+
+// unlockFunc returns the function to unlock the state of the given workspace,
+// which sets the given error if the unlock fails and there is no other error
+func unlockFunc(backend StateBackend, workspace, id string) func(err *error) {
+	return func(err *error) {
+		if uerr := backend.Unlock(workspace, id); uerr != nil && *err == nil {
+			*err = fmt.Errorf("failed to unlock the state. %w", uerr)
+		}
+	}
+}
+
+// isStaleLock returns true if the lock is older than the given timeout
+func isStaleLock(info *LockInfo, timeout time.Duration) bool {
+	if timeout <= 0 || info == nil || info.Created.IsZero() {
+		return false
+	}
+	return time.Since(info.Created) > timeout
+}
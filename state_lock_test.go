@@ -0,0 +1,141 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPlatform_LockState(t *testing.T) {
+	defer func(delay time.Duration) { lockRetryDelay = delay }(lockRetryDelay)
+	lockRetryDelay = 10 * time.Millisecond
+
+	tmpDir, err := ioutil.TempDir("", ".terranova_lock_test")
+	if err != nil {
+		t.Fatalf("Failed to create temporal directory. %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tests := []struct {
+		name         string
+		lockInfo     *LockInfo
+		lockTimeout  time.Duration
+		staleTimeout time.Duration
+		unlockAfter  time.Duration
+		wantLocked   bool
+		wantWarning  bool
+	}{
+		{"not locked", nil, 0, 0, 0, false, false},
+		{"locked", &LockInfo{Operation: "apply"}, 0, 0, 0, true, false},
+		{"locked until timeout", &LockInfo{Operation: "apply"}, 50 * time.Millisecond, 0, time.Second, true, false},
+		{"unlocked before timeout", &LockInfo{Operation: "apply"}, time.Second, 0, 50 * time.Millisecond, false, false},
+		{"stale lock", &LockInfo{Operation: "apply", Created: time.Now().Add(-time.Hour)}, 0, time.Minute, 0, false, true},
+		{"not stale lock", &LockInfo{Operation: "apply", Created: time.Now()}, 0, time.Minute, 0, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(tmpDir, tt.name+".tfstate")
+			backend := NewFileBackend(filename)
+
+			p, err := newPlatformForTest(testsPlatformsFields["test instance"]).PersistStateTo(backend)
+			if err != nil {
+				t.Fatalf("Platform.PersistStateTo() error = %v", err)
+			}
+			p.LockTimeout(tt.lockTimeout).StaleLockTimeout(tt.staleTimeout)
+
+			var id string
+			if tt.lockInfo != nil {
+				if id, err = backend.Lock(DefaultWorkspace, tt.lockInfo); err != nil {
+					t.Fatalf("FileBackend.Lock() error = %v", err)
+				}
+			}
+			if tt.unlockAfter != 0 {
+				timer := time.AfterFunc(tt.unlockAfter, func() { backend.Unlock(DefaultWorkspace, id) })
+				defer timer.Stop()
+			}
+
+			_, err = p.Plan(false)
+			var lockedErr *StateLockedError
+			if got := errors.As(err, &lockedErr); got != tt.wantLocked {
+				t.Fatalf("Platform.Plan() error = %v, want StateLockedError %v", err, tt.wantLocked)
+			}
+			if tt.wantLocked {
+				if lockedErr.Info == nil || lockedErr.Info.ID != id || lockedErr.Info.Operation != "apply" {
+					t.Errorf("Platform.Plan() lock info = %+v, want lock ID %q", lockedErr.Info, id)
+				}
+				if err := p.ForceUnlock(lockedErr.Info.ID); err != nil {
+					t.Fatalf("Platform.ForceUnlock() error = %v", err)
+				}
+				if _, err = p.Plan(false); err != nil {
+					t.Fatalf("Platform.Plan() after ForceUnlock() error = %v", err)
+				}
+			}
+			if err != nil {
+				t.Fatalf("Platform.Plan() error = %v", err)
+			}
+
+			if _, err := os.Stat(lockPath(filename)); !os.IsNotExist(err) {
+				t.Errorf("the state lock file was not removed")
+			}
+			if got := len(p.Warnings()) != 0; got != tt.wantWarning {
+				t.Errorf("Platform.Warnings() = %v, want a warning %v", p.Warnings(), tt.wantWarning)
+			}
+		})
+	}
+
+	// A lock file that can't be read has a lock without ID
+	filename := filepath.Join(tmpDir, "unreadable.tfstate")
+	p, err := newPlatformForTest(testsPlatformsFields["test instance"]).PersistStateTo(NewFileBackend(filename))
+	if err != nil {
+		t.Fatalf("Platform.PersistStateTo() error = %v", err)
+	}
+	for _, stale := range []bool{false, true} {
+		if err := ioutil.WriteFile(lockPath(filename), []byte(`{"ID": "trunc`), 0644); err != nil {
+			t.Fatalf("fail to write the lock file. %s", err)
+		}
+		if stale {
+			old := time.Now().Add(-time.Hour)
+			if err := os.Chtimes(lockPath(filename), old, old); err != nil {
+				t.Fatalf("fail to change the lock file time. %s", err)
+			}
+			p.StaleLockTimeout(time.Minute)
+		} else {
+			var lockedErr *StateLockedError
+			if _, err := p.Plan(false); !errors.As(err, &lockedErr) || lockedErr.Info == nil || len(lockedErr.Info.ID) != 0 {
+				t.Fatalf("Platform.Plan() error = %v, want StateLockedError without lock ID", err)
+			}
+			if err := p.ForceUnlock(""); err != nil {
+				t.Fatalf("Platform.ForceUnlock() error = %v", err)
+			}
+		}
+		if _, err := p.Plan(false); err != nil {
+			t.Errorf("Platform.Plan() of an unreadable lock, stale %v, error = %v", stale, err)
+		}
+		if _, err := os.Stat(lockPath(filename)); !os.IsNotExist(err) {
+			t.Errorf("the unreadable lock file was not removed, stale %v", stale)
+		}
+	}
+
+	if err := NewPlatform("").ForceUnlock("id"); !errors.Is(err, ErrNoStateBackend) {
+		t.Errorf("Platform.ForceUnlock() error = %v, want %v", err, ErrNoStateBackend)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/hashicorp/terraform/states/statemgr"
+)
+
+// MigrateState copies the state of the given workspaces, or of every workspace
+// if none is given, from one state backend to another. The state of a
+// workspace is locked in both backends while it's copied, then it's read back
+// to verify it. A state in the destination backend with a different lineage or
+// a higher serial is not overwritten, a *StateConflictError is returned.
+func MigrateState(from, to StateBackend, workspaces ...string) error {
+	if len(workspaces) == 0 {
+		var err error
+		if workspaces, err = from.Workspaces(); err != nil {
+			return err
+		}
+	}
+
+	for _, workspace := range workspaces {
+		if err := migrateWorkspace(from, to, workspace); err != nil {
+			return fmt.Errorf("failed to migrate the state of workspace %q. %w", workspace, err)
+		}
+	}
+	return nil
+}
+
+// migrateWorkspace copies the state of the given workspace, if there is one,
+// unless the destination backend already has it
+func migrateWorkspace(from, to StateBackend, workspace string) (err error) {
+	info := statemgr.NewLockInfo()
+	info.Operation = "migrate"
+
+	fromID, err := from.Lock(workspace, info)
+	if err != nil {
+		return err
+	}
+	defer unlockFunc(from, workspace, fromID)(&err)
+
+	toID, err := to.Lock(workspace, info)
+	if err != nil {
+		return err
+	}
+	defer unlockFunc(to, workspace, toID)(&err)
+
+	sf, err := from.ReadState(workspace)
+	if err != nil || sf == nil {
+		return err
+	}
+
+	stored, err := to.ReadState(workspace)
+	if err != nil {
+		return err
+	}
+	if stored != nil {
+		conflictErr := &StateConflictError{
+			Lineage:       sf.Lineage,
+			Serial:        sf.Serial,
+			StoredLineage: stored.Lineage,
+			StoredSerial:  stored.Serial,
+		}
+		switch {
+		case stored.Lineage != sf.Lineage || stored.Serial > sf.Serial:
+			return conflictErr
+		case stored.Serial == sf.Serial:
+			if statefile.StatesMarshalEqual(stored.State, sf.State) {
+				// Already migrated
+				return nil
+			}
+			return conflictErr
+		}
+	}
+
+	if err := to.WriteState(workspace, sf); err != nil {
+		return err
+	}
+
+	migrated, err := to.ReadState(workspace)
+	if err != nil {
+		return err
+	}
+	if migrated == nil || migrated.Lineage != sf.Lineage || migrated.Serial != sf.Serial || !statefile.StatesMarshalEqual(migrated.State, sf.State) {
+		return fmt.Errorf("the migrated state doesn't match the original state with lineage %q and serial %d", sf.Lineage, sf.Serial)
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/states/statefile"
+)
+
+func TestMigrateState(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", ".terranova_migrate_test")
+	if err != nil {
+		t.Fatalf("Failed to create temporal directory. %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	from := NewFileBackend(filepath.Join(tmpDir, "test.tfstate"))
+	for _, workspace := range []string{DefaultWorkspace, "staging", "dev"} {
+		if err := from.WriteState(workspace, statefile.New(testInstancesState(workspace), "lineage-"+workspace, 3)); err != nil {
+			t.Fatalf("FileBackend.WriteState() error = %v", err)
+		}
+	}
+
+	to := NewMemoryBackend()
+	if err := MigrateState(from, to); err != nil {
+		t.Fatalf("MigrateState() error = %v", err)
+	}
+	if got, err := to.Workspaces(); err != nil || !reflect.DeepEqual(got, []string{DefaultWorkspace, "dev", "staging"}) {
+		t.Errorf("MigrateState() workspaces = %v, %v, want [default dev staging]", got, err)
+	}
+	for _, workspace := range []string{DefaultWorkspace, "staging", "dev"} {
+		want, _ := from.ReadState(workspace)
+		got, _ := to.ReadState(workspace)
+		if got == nil || got.Lineage != want.Lineage || got.Serial != want.Serial || !statefile.StatesMarshalEqual(got.State, want.State) {
+			t.Errorf("MigrateState() state of %q = %+v, want %+v", workspace, got, want)
+		}
+	}
+
+	// The locks are released and migrating again does nothing
+	if err := MigrateState(from, to); err != nil {
+		t.Errorf("MigrateState() of migrated states error = %v", err)
+	}
+
+	conflict := func(err error) bool {
+		var conflictErr *StateConflictError
+		return errors.As(err, &conflictErr)
+	}
+	locked := func(err error) bool {
+		var lockedErr *StateLockedError
+		return errors.As(err, &lockedErr)
+	}
+	tests := []struct {
+		name      string
+		workspace string
+		stored    *statefile.File
+		lock      StateBackend
+		want      *statefile.File
+		wantErr   func(error) bool
+	}{
+		{"older state", "dev", statefile.New(testInstancesState("old"), "lineage-dev", 2), nil, statefile.New(testInstancesState("dev"), "lineage-dev", 3), nil},
+		{"newer state", "dev", statefile.New(testInstancesState("new"), "lineage-dev", 4), nil, statefile.New(testInstancesState("new"), "lineage-dev", 4), conflict},
+		{"same serial", "dev", statefile.New(testInstancesState("new"), "lineage-dev", 3), nil, statefile.New(testInstancesState("new"), "lineage-dev", 3), conflict},
+		{"other lineage", "dev", statefile.New(testInstancesState("dev"), "other", 1), nil, statefile.New(testInstancesState("dev"), "other", 1), conflict},
+		{"source locked", "staging", nil, from, statefile.New(testInstancesState("staging"), "lineage-staging", 3), locked},
+		{"destination locked", "staging", nil, to, statefile.New(testInstancesState("staging"), "lineage-staging", 3), locked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.stored != nil {
+				to.WriteState(tt.workspace, tt.stored)
+			}
+			if tt.lock != nil {
+				id, err := tt.lock.Lock(tt.workspace, &LockInfo{Operation: "test"})
+				if err != nil {
+					t.Fatalf("Lock() error = %v", err)
+				}
+				defer tt.lock.Unlock(tt.workspace, id)
+			}
+
+			err := MigrateState(from, to, tt.workspace)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("MigrateState() error = %v", err)
+			}
+			if tt.wantErr != nil && !tt.wantErr(err) {
+				t.Fatalf("MigrateState() error = %v", err)
+			}
+
+			got, _ := to.ReadState(tt.workspace)
+			if got == nil || got.Lineage != tt.want.Lineage || got.Serial != tt.want.Serial || !statefile.StatesMarshalEqual(got.State, tt.want.State) {
+				t.Errorf("MigrateState() state = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// The migrated state is verified
+	if err := MigrateState(from, &truncatingBackend{NewMemoryBackend()}); err == nil {
+		t.Errorf("MigrateState() to a backend losing the state error = nil, want an error")
+	}
+}
+
+// truncatingBackend is a StateBackend saving an empty state
+type truncatingBackend struct {
+	*MemoryBackend
+}
+
+func (b *truncatingBackend) WriteState(workspace string, sf *statefile.File) error {
+	return b.MemoryBackend.WriteState(workspace, statefile.New(testInstancesState(), sf.Lineage, sf.Serial))
+}
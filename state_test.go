@@ -18,6 +18,8 @@ package terranova
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -52,7 +54,10 @@ func TestPlatform_WriteState(t *testing.T) {
 				t.Errorf("Platform.WriteState() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if gotW := w.String(); gotW != tt.wantW {
+			if len(p.lineage) == 0 {
+				t.Errorf("Platform.WriteState() wrote a state without lineage")
+			}
+			if gotW := withoutLineage(w.String(), p.lineage); gotW != tt.wantW {
 				t.Errorf("Platform.WriteState() = %v, want %v", gotW, tt.wantW)
 			}
 		})
@@ -72,7 +77,7 @@ func TestPlatform_WriteState(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to read the temporal file %q. %s", f.Name(), err)
 	}
-	if string(got) != testStateStr {
+	if withoutLineage(string(got), p.lineage) != testStateStr {
 		t.Errorf("Platform.WriteStateToFile() wrote %v, want %v", got, testStateStr)
 	}
 }
@@ -182,6 +187,117 @@ func TestPlatform_PersistStateToFile(t *testing.T) {
 	}
 }
 
+func TestPlatform_StateLineageSerial(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", ".terranova_state_test")
+	if err != nil {
+		t.Fatalf("Failed to create temporal directory. %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// The lineage and serial read are kept when the state is written
+	stateStr := strings.Replace(emptyStateStr, `"lineage": ""`, `"lineage": "test-lineage"`, 1)
+	stateStr = strings.Replace(stateStr, `"serial": 0`, `"serial": 5`, 1)
+	p, err := NewPlatform("").ReadState(strings.NewReader(stateStr))
+	if err != nil {
+		t.Fatalf("Platform.ReadState() error = %v", err)
+	}
+	var w bytes.Buffer
+	if _, err := p.WriteState(&w); err != nil {
+		t.Fatalf("Platform.WriteState() error = %v", err)
+	}
+	if got := w.String(); got != stateStr {
+		t.Errorf("Platform.WriteState() = %v, want %v", got, stateStr)
+	}
+
+	// A lineage is generated for a state without lineage
+	p, err = NewPlatform("").ReadState(strings.NewReader(emptyStateStr))
+	if err != nil {
+		t.Fatalf("Platform.ReadState() error = %v", err)
+	}
+	if len(p.lineage) == 0 {
+		t.Errorf("Platform.ReadState() did not generate a lineage")
+	}
+
+	// Every change increments the serial of the persisted state
+	filename := filepath.Join(tmpDir, "test.tfstate")
+	p1 := newPlatformForTest(platformFields{State: testInstancesState("foo")})
+	if _, err := p1.PersistStateToFile(filename); err != nil {
+		t.Fatalf("Platform.PersistStateToFile() error = %v", err)
+	}
+	p2 := NewPlatform("")
+	if _, err := p2.PersistStateToFile(filename); err != nil {
+		t.Fatalf("Platform.PersistStateToFile() error = %v", err)
+	}
+	if _, err := p1.Taint("test_instance.foo"); err != nil {
+		t.Fatalf("Platform.Taint() error = %v", err)
+	}
+	sf := readStateFileMeta(t, filename)
+	if sf.Lineage != p1.lineage || sf.Serial != 1 {
+		t.Errorf("persisted lineage and serial = %q, %d, want %q, 1", sf.Lineage, sf.Serial, p1.lineage)
+	}
+
+	// A newer state is not overwritten
+	var conflictErr *StateConflictError
+	if _, err := p2.Taint("test_instance.foo"); !errors.As(err, &conflictErr) {
+		t.Errorf("Platform.Taint() error = %v, want %T", err, conflictErr)
+	}
+	if sf := readStateFileMeta(t, filename); sf.Serial != 1 {
+		t.Errorf("persisted serial = %d, want 1", sf.Serial)
+	}
+
+	// A state with a different lineage is not overwritten
+	if _, err := NewPlatform("").WriteStateToFile(filename); !errors.As(err, &conflictErr) {
+		t.Errorf("Platform.WriteStateToFile() error = %v, want %T", err, conflictErr)
+	}
+	if _, err := p1.WriteStateToFile(filename); err != nil {
+		t.Errorf("Platform.WriteStateToFile() error = %v", err)
+	}
+}
+
+func TestPlatform_Apply_WriteStateToFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", ".terranova_state_test")
+	if err != nil {
+		t.Fatalf("Failed to create temporal directory. %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	filename := filepath.Join(tmpDir, "test.tfstate")
+
+	p := newPlatformForTest(testsPlatformsFields["test instance"])
+	if _, err := p.WriteStateToFile(filename); err != nil {
+		t.Fatalf("Platform.WriteStateToFile() error = %v", err)
+	}
+	for i, destroy := range []bool{false, false, true, false} {
+		if err := p.Apply(destroy); err != nil {
+			t.Fatalf("Platform.Apply(%v) #%d error = %v", destroy, i, err)
+		}
+		if _, err := p.WriteStateToFile(filename); err != nil {
+			t.Fatalf("Platform.WriteStateToFile() #%d error = %v", i, err)
+		}
+		if sf := readStateFileMeta(t, filename); sf.Lineage != p.lineage || sf.Serial != p.serial {
+			t.Errorf("Platform.WriteStateToFile() #%d lineage and serial = %q, %d, want %q, %d", i, sf.Lineage, sf.Serial, p.lineage, p.serial)
+		}
+	}
+}
+
+func readStateFileMeta(t *testing.T, filename string) *statefile.File {
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("fail to open state file %q. %s", filename, err)
+	}
+	defer file.Close()
+
+	sf, err := statefile.Read(file)
+	if err != nil {
+		t.Fatalf("fail to read state file %q. %s", filename, err)
+	}
+	return sf
+}
+
+// withoutLineage removes the given lineage from the state file content
+func withoutLineage(stateStr, lineage string) string {
+	return strings.Replace(stateStr, fmt.Sprintf(`"lineage": %q`, lineage), `"lineage": ""`, 1)
+}
+
 func readStateFile(filename string) (*State, error) {
 	file, err := os.Open(filename)
 	defer file.Close()
@@ -0,0 +1,118 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states"
+)
+
+// Taint marks the given resource instance, i.e. `aws_instance.server[0]`, as
+// tainted, so it will be destroyed and created again in the next Apply. The
+// change is saved with the state manager, if any.
+func (p *Platform) Taint(addr string) (*Platform, error) {
+	return p, p.setInstanceStatus(addr, states.ObjectTainted)
+}
+
+// Untaint removes the tainted mark of the given resource instance, so it won't
+// be replaced in the next Apply. The change is saved with the state manager, if
+// any.
+func (p *Platform) Untaint(addr string) (*Platform, error) {
+	return p, p.setInstanceStatus(addr, states.ObjectReady)
+}
+
+// setInstanceStatus changes the status of the current object of the given
+// managed resource instance
+func (p *Platform) setInstanceStatus(addr string, status states.ObjectStatus) error {
+	instAddr, err := parseResourceInstance(addr)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	obj, err := currentObject(p.State, instAddr)
+	if err != nil {
+		return err
+	}
+	if status == states.ObjectReady && obj.Status != states.ObjectTainted {
+		return fmt.Errorf("%w: %s", ErrNotTainted, addr)
+	}
+	if obj.Status == status {
+		return nil
+	}
+
+	obj.Status = status
+	rs := p.State.Resource(instAddr.ContainingResource())
+	p.State.Module(instAddr.Module).SetResourceInstanceCurrent(instAddr.Resource, obj, rs.ProviderConfig)
+	p.serial++
+
+	return p.persistState()
+}
+
+// taintedState returns a copy of the state with the given resource instances
+// tainted, so they are planned to be replaced
+func taintedState(state *State, instances []string) (*State, error) {
+	if len(instances) == 0 {
+		return state, nil
+	}
+
+	state = state.DeepCopy()
+	for _, addr := range instances {
+		instAddr, err := parseResourceInstance(addr)
+		if err != nil {
+			return nil, err
+		}
+		obj, err := currentObject(state, instAddr)
+		if err != nil {
+			return nil, err
+		}
+		obj.Status = states.ObjectTainted
+		rs := state.Resource(instAddr.ContainingResource())
+		state.Module(instAddr.Module).SetResourceInstanceCurrent(instAddr.Resource, obj, rs.ProviderConfig)
+	}
+
+	return state, nil
+}
+
+// currentObject returns a copy of the current object of the given managed
+// resource instance in the state
+func currentObject(state *State, addr addrs.AbsResourceInstance) (*states.ResourceInstanceObjectSrc, error) {
+	if addr.Resource.Resource.Mode != addrs.ManagedResourceMode {
+		return nil, fmt.Errorf("%s is not a managed resource", addr)
+	}
+	if state == nil {
+		return nil, &ResourceNotFoundError{Addr: addr.String()}
+	}
+	ri := state.ResourceInstance(addr)
+	if ri == nil || ri.Current == nil {
+		return nil, &ResourceNotFoundError{Addr: addr.String()}
+	}
+	return ri.Current.DeepCopy(), nil
+}
+
+// parseResourceInstance parses the given resource instance address
+func parseResourceInstance(addr string) (addrs.AbsResourceInstance, error) {
+	instAddr, diags := addrs.ParseAbsResourceInstanceStr(addr)
+	if diags.HasErrors() {
+		return instAddr, fmt.Errorf("invalid resource instance address %q. %w", addr, newDiagnosticsError(diags, nil))
+	}
+	return instAddr, nil
+}
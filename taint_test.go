@@ -0,0 +1,152 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform/plans"
+	"github.com/hashicorp/terraform/providers"
+	"github.com/hashicorp/terraform/states"
+)
+
+func TestPlatform_Taint_Untaint(t *testing.T) {
+	tests := []struct {
+		name       string
+		opFn       func(p *Platform) (*Platform, error)
+		wantStatus states.ObjectStatus
+		wantErr    bool
+	}{
+		{"taint", func(p *Platform) (*Platform, error) { return p.Taint("test_instance.foo") }, states.ObjectTainted, false},
+		{"taint twice", func(p *Platform) (*Platform, error) {
+			p.Taint("test_instance.foo")
+			return p.Taint("test_instance.foo")
+		}, states.ObjectTainted, false},
+		{"untaint", func(p *Platform) (*Platform, error) {
+			p.Taint("test_instance.foo")
+			return p.Untaint("test_instance.foo")
+		}, states.ObjectReady, false},
+		{"untaint not tainted", func(p *Platform) (*Platform, error) { return p.Untaint("test_instance.foo") }, states.ObjectReady, true},
+		{"not found", func(p *Platform) (*Platform, error) { return p.Taint("test_instance.bar") }, states.ObjectReady, true},
+		{"invalid address", func(p *Platform) (*Platform, error) { return p.Taint("test_instance") }, states.ObjectReady, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, err := ioutil.TempDir("", ".terranova_taint_test")
+			if err != nil {
+				t.Fatalf("Failed to create temporal directory. %s", err)
+			}
+			defer os.RemoveAll(tmpDir)
+			filename := filepath.Join(tmpDir, "test.tfstate")
+
+			p := newPlatformForTest(platformFields{
+				Code:  testInstancesCode("foo"),
+				State: testInstancesState("foo"),
+			})
+			if _, err := p.PersistStateToFile(filename); err != nil {
+				t.Fatalf("Platform.PersistStateToFile() error = %v", err)
+			}
+
+			if _, err := tt.opFn(p); (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := p.State.ResourceInstance(testInstanceAddr("foo")).Current.Status; got != tt.wantStatus {
+				t.Errorf("status = %v, want %v", got, tt.wantStatus)
+			}
+			state, err := readStateFile(filename)
+			if err != nil {
+				t.Fatalf("fail to read state file %q. %s", filename, err)
+			}
+			if got := state.ResourceInstance(testInstanceAddr("foo")).Current.Status; got != tt.wantStatus {
+				t.Errorf("persisted status = %v, want %v", got, tt.wantStatus)
+			}
+		})
+	}
+
+	p := newPlatformForTest(platformFields{State: testInstancesState("foo")})
+	var notFoundErr *ResourceNotFoundError
+	if _, err := p.Taint("test_instance.bar"); !errors.As(err, &notFoundErr) {
+		t.Errorf("Platform.Taint() error = %v, want %T", err, notFoundErr)
+	}
+	if _, err := p.Untaint("test_instance.foo"); !errors.Is(err, ErrNotTainted) {
+		t.Errorf("Platform.Untaint() error = %v, want %v", err, ErrNotTainted)
+	}
+}
+
+func TestPlatform_Replace(t *testing.T) {
+	tests := []struct {
+		name       string
+		taint      string
+		replace    []string
+		wantAction plans.Action
+		wantErr    bool
+	}{
+		{"no replacement", "", nil, plans.NoOp, false},
+		{"tainted", "test_instance.foo", nil, plans.DeleteThenCreate, false},
+		{"forced replacement", "", []string{"test_instance.foo"}, plans.DeleteThenCreate, false},
+		{"forced replacement not found", "", []string{"test_instance.bar"}, plans.NoOp, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPlatformForTest(platformFields{
+				Code:  testInstancesCode("foo"),
+				State: testInstancesState("foo"),
+				Providers: map[string]providers.Factory{
+					"test": providers.FactoryFixed(NewMockProvider(nil, "test", testSimpleSchema())),
+				},
+			}).Replace(tt.replace...)
+			if len(tt.taint) != 0 {
+				if _, err := p.Taint(tt.taint); err != nil {
+					t.Fatalf("Platform.Taint() error = %v", err)
+				}
+			}
+
+			plan, err := p.Plan(false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Platform.Plan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := plan.Changes.Resources[0].Action; got != tt.wantAction {
+				t.Errorf("Platform.Plan() action = %v, want %v", got, tt.wantAction)
+			}
+			if len(tt.replace) != 0 && p.State.ResourceInstance(testInstanceAddr("foo")).Current.Status != states.ObjectReady {
+				t.Errorf("Platform.Replace() modified the platform state")
+			}
+
+			// The replacement is done once
+			if err := p.ApplyPlan(plan); err != nil {
+				t.Fatalf("Platform.ApplyPlan() error = %v", err)
+			}
+			if plan, err = p.Plan(false); err != nil {
+				t.Fatalf("Platform.Plan() error = %v", err)
+			}
+			if got := plan.Changes.Resources[0].Action; got.IsReplace() {
+				t.Errorf("Platform.Plan() after applying action = %v, want no replacement", got)
+			}
+			if len(p.ForceReplace) != 0 {
+				t.Errorf("Platform.ForceReplace = %v after applying, want none", p.ForceReplace)
+			}
+		})
+	}
+}
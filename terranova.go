@@ -17,87 +17,407 @@ limitations under the License.
 package terranova
 
 import (
-	"bufio"
+	"context"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strings"
+	"sort"
+	"sync/atomic"
 
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/hashicorp/terraform/addrs"
 	"github.com/hashicorp/terraform/backend/local"
 	"github.com/hashicorp/terraform/configs"
 	"github.com/hashicorp/terraform/configs/configload"
+	"github.com/hashicorp/terraform/configs/configschema"
 	"github.com/hashicorp/terraform/plans"
 	"github.com/hashicorp/terraform/providers"
 	"github.com/hashicorp/terraform/terraform"
+	"github.com/hashicorp/terraform/tfdiags"
 	"github.com/zclconf/go-cty/cty"
 )
 
 // Apply brings the platform to the desired state. It'll destroy the platform
 // when `destroy` is `true`.
 func (p *Platform) Apply(destroy bool) error {
-	p.startMiddleware()
+	return p.ApplyContext(context.Background(), destroy)
+}
 
-	p.countHook = new(local.CountHook)
-	stateHook := new(local.StateHook)
+// ApplyContext is like Apply but it watches the given context. If the context
+// is cancelled or its deadline exceeded, Terraform is stopped, the partial state
+// is saved and a *CancelError is returned.
+func (p *Platform) ApplyContext(ctx context.Context, destroy bool) (err error) {
+	p.startMiddleware()
+	p.resetWarnings()
 
-	if p.Hooks == nil {
-		p.Hooks = []terraform.Hook{}
+	unlock, err := p.lockState(ctx, "apply")
+	if err != nil {
+		return err
 	}
-	p.Hooks = append(p.Hooks, p.countHook, stateHook)
+	defer unlock(&err)
 
-	ctx, err := p.newContext(destroy)
+	stateHook := p.newApplyHooks()
+
+	tfCtx, _, err := p.newContext(destroy, p.ForceReplace, p.countHook, stateHook)
 	if err != nil {
 		return err
 	}
 
-	// state := ctx.State()
+	stop, stopped := stopOnDone(ctx, tfCtx)
+	defer stop()
 
-	if _, diag := ctx.Refresh(); diag.HasErrors() {
-		return diag.Err()
+	_, diag := tfCtx.Refresh()
+	p.addWarnings(diag, codeDir(tfCtx.Config()))
+	if err := cancelled(ctx, "refresh"); err != nil {
+		return err
+	}
+	if diag.HasErrors() {
+		return newDiagnosticsError(diag, tfCtx.Config())
 	}
 
-	plan, diag := ctx.Plan()
+	plan, diag := tfCtx.Plan()
+	p.addWarnings(diag, codeDir(tfCtx.Config()))
+	if err := cancelled(ctx, "plan"); err != nil {
+		return err
+	}
 	if diag.HasErrors() {
-		return diag.Err()
+		return newDiagnosticsError(diag, tfCtx.Config())
+	}
+
+	return p.apply(ctx, tfCtx, plan, stateHook, stopped)
+}
+
+// ApplyPlan applies exactly the changes of the given plan, created with Plan,
+// to the state the plan was created against. The plan is rejected if the
+// platform state has changed since the plan was created, or if it was
+// discarded because more than MaxPlans plans were created or read after it.
+func (p *Platform) ApplyPlan(plan *plans.Plan) error {
+	return p.ApplyPlanContext(context.Background(), plan)
+}
+
+// ApplyPlanContext is like ApplyPlan but it watches the given context. If the
+// context is cancelled or its deadline exceeded, Terraform is stopped, the
+// partial state is saved and a *CancelError is returned.
+func (p *Platform) ApplyPlanContext(ctx context.Context, plan *plans.Plan) (err error) {
+	if plan == nil {
+		return ErrNoPlan
+	}
+
+	p.mu.Lock()
+	prior, ok := p.planned[plan]
+	p.mu.Unlock()
+	if !ok {
+		return ErrUnknownPlan
+	}
+
+	p.startMiddleware()
+	p.resetWarnings()
+
+	unlock, err := p.lockState(ctx, "apply")
+	if err != nil {
+		return err
+	}
+	defer unlock(&err)
+
+	if prior.lineage != p.lineage || prior.serial != p.serial {
+		return ErrStalePlan
+	}
+	if plan.Backend.Workspace != p.workspace {
+		return fmt.Errorf("%w, it was created for the workspace %q", ErrStalePlan, plan.Backend.Workspace)
+	}
+
+	stateHook := p.newApplyHooks()
+
+	vars, err := planVariables(plan)
+	if err != nil {
+		return err
+	}
+
+	tfCtx, err := p.newContextFromOpts(&terraform.ContextOpts{
+		Config:           prior.config,
+		Changes:          plan.Changes,
+		State:            prior.state,
+		Targets:          plan.TargetAddrs,
+		Variables:        vars,
+		ProviderResolver: providers.ResolverFixed(p.Providers),
+		Provisioners:     p.Provisioners,
+		ProviderSHA256s:  plan.ProviderSHA256s,
+		Hooks:            p.hooks(p.countHook, stateHook),
+		Meta:             &terraform.ContextMeta{Env: p.workspace},
+	})
+	if err != nil {
+		return err
+	}
+
+	stop, stopped := stopOnDone(ctx, tfCtx)
+	defer stop()
+
+	if err := cancelled(ctx, "apply"); err != nil {
+		return err
 	}
+
+	p.mu.Lock()
+	p.removePlanned(plan)
+	p.mu.Unlock()
+
+	return p.apply(ctx, tfCtx, plan, stateHook, stopped)
+}
+
+// newApplyHooks creates the hooks required to count the applied changes and to
+// save the state every time it changes, used only by the current apply. Returns
+// the state hook to assign the state manager right before applying the changes.
+func (p *Platform) newApplyHooks() *local.StateHook {
+	p.countHook = new(local.CountHook)
+	return new(local.StateHook)
+}
+
+// hooks returns the platform hooks followed by the given hooks, without
+// modifying the platform hooks
+func (p *Platform) hooks(hooks ...terraform.Hook) []terraform.Hook {
+	return append(append([]terraform.Hook{}, p.Hooks...), hooks...)
+}
+
+// apply applies the given plan with the Terraform context and keeps the
+// resulting state, even if the apply failed or was cancelled. The apply was
+// cancelled only if the Terraform context was stopped, as reported by stopped.
+func (p *Platform) apply(ctx context.Context, tfCtx *terraform.Context, plan *plans.Plan, stateHook *local.StateHook, stopped func() bool) error {
 	p.ExpectedStats = NewStats().FromPlan(plan)
 
-	stateHook.StateMgr = p.stateMgr
+	// Every state saved while applying the changes is a new serial of the state
+	p.serial++
+	if p.backend != nil {
+		stateHook.StateMgr = &stateWriter{p: p}
+	}
 
-	sts, diag := ctx.Apply()
-	p.State = sts
-	// p.State = ctx.State()
+	sts, diag := tfCtx.Apply()
+	interrupted := stopped()
+	p.addWarnings(diag, codeDir(tfCtx.Config()))
+	perr := p.setAppliedState(sts)
 
+	if err := cancelled(ctx, "apply"); err != nil && interrupted {
+		if perr != nil {
+			return fmt.Errorf("%w. Failed to save the partial state. %s", err, perr)
+		}
+		return err
+	}
 	if diag.HasErrors() {
-		return diag.Err()
+		return newDiagnosticsError(diag, tfCtx.Config())
+	}
+	if perr != nil {
+		return fmt.Errorf("failed to save the state. %w", perr)
 	}
+
+	// The forced replacements are done
+	p.ForceReplace = nil
+
 	return nil
 }
 
 // Plan returns execution plan for an existing configuration to apply to the
-// platform.
+// platform. Only the last MaxPlans plans can be applied, a plan to apply
+// after a long review should be saved with WritePlan and read with ReadPlan.
 func (p *Platform) Plan(destroy bool) (*plans.Plan, error) {
+	return p.PlanContext(context.Background(), destroy)
+}
+
+// PlanContext is like Plan but it watches the given context. If the context is
+// cancelled or its deadline exceeded, Terraform is stopped and a *CancelError
+// is returned.
+func (p *Platform) PlanContext(ctx context.Context, destroy bool) (plan *plans.Plan, err error) {
 	p.startMiddleware()
+	p.resetWarnings()
 
-	ctx, err := p.newContext(destroy)
+	unlock, err := p.lockState(ctx, "plan")
 	if err != nil {
 		return nil, err
 	}
+	defer unlock(&err)
+
+	tfCtx, snap, err := p.newContext(destroy, p.ForceReplace)
+	if err != nil {
+		return nil, err
+	}
+
+	stop, _ := stopOnDone(ctx, tfCtx)
+	defer stop()
 
-	if _, diag := ctx.Refresh(); diag.HasErrors() {
-		return nil, diag.Err()
+	_, diag := tfCtx.Refresh()
+	p.addWarnings(diag, codeDir(tfCtx.Config()))
+	if err := cancelled(ctx, "refresh"); err != nil {
+		return nil, err
+	}
+	if diag.HasErrors() {
+		return nil, newDiagnosticsError(diag, tfCtx.Config())
 	}
 
-	plan, diag := ctx.Plan()
+	plan, diag = tfCtx.Plan()
+	p.addWarnings(diag, codeDir(tfCtx.Config()))
+	if err := cancelled(ctx, "plan"); err != nil {
+		return nil, err
+	}
 	if diag.HasErrors() {
-		return nil, diag.Err()
+		return nil, newDiagnosticsError(diag, tfCtx.Config())
+	}
+
+	// A backend is required to save the plan to a plan file
+	planBackend, err := plans.NewBackend("local", cty.EmptyObjectVal, &configschema.Block{}, p.workspace)
+	if err != nil {
+		return nil, err
 	}
+	plan.Backend = *planBackend
+
+	p.addPlanned(plan, &plannedState{
+		lineage:  p.lineage,
+		serial:   p.serial,
+		config:   tfCtx.Config(),
+		snapshot: snap,
+		state:    tfCtx.State(),
+	})
 
 	return plan, nil
 }
 
+// plannedState is the configuration and state a plan was created against, it's
+// required to apply the plan later
+type plannedState struct {
+	lineage  string
+	serial   uint64
+	config   *configs.Config
+	snapshot *configload.Snapshot
+	state    *State
+}
+
+// defaultMaxPlanned is the number of plans the platform keeps to be applied or
+// written later if it's not set with MaxPlans
+const defaultMaxPlanned = 10
+
+// addPlanned keeps the configuration and state the given plan was created
+// against, discarding the oldest plans if there are more than MaxPlans
+func (p *Platform) addPlanned(plan *plans.Plan, prior *plannedState) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.planned == nil {
+		p.planned = make(map[*plans.Plan]*plannedState)
+	}
+	if _, ok := p.planned[plan]; !ok {
+		p.plannedOrder = append(p.plannedOrder, plan)
+	}
+	p.planned[plan] = prior
+
+	p.discardOldestPlanned()
+}
+
+// discardOldestPlanned forgets the oldest plans if there are more than
+// MaxPlans. The platform lock has to be held by the caller.
+func (p *Platform) discardOldestPlanned() {
+	max := p.maxPlans
+	if max <= 0 {
+		max = defaultMaxPlanned
+	}
+	for len(p.plannedOrder) > max {
+		p.removePlanned(p.plannedOrder[0])
+	}
+}
+
+// removePlanned forgets the given plan. The platform lock has to be held by
+// the caller.
+func (p *Platform) removePlanned(plan *plans.Plan) {
+	delete(p.planned, plan)
+	for i, planned := range p.plannedOrder {
+		if planned == plan {
+			p.plannedOrder = append(p.plannedOrder[:i], p.plannedOrder[i+1:]...)
+			break
+		}
+	}
+}
+
+// planVariables returns the variables values recorded in the plan
+func planVariables(plan *plans.Plan) (terraform.InputValues, error) {
+	iv := make(terraform.InputValues)
+	for name, dv := range plan.VariableValues {
+		val, err := dv.Decode(cty.DynamicPseudoType)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for variable %q recorded in the plan. %s", name, err)
+		}
+		iv[name] = &terraform.InputValue{
+			Value:      val,
+			SourceType: terraform.ValueFromPlan,
+		}
+	}
+	return iv, nil
+}
+
+// cancelled returns a *CancelError if the given context is done
+func cancelled(ctx context.Context, op string) error {
+	if err := ctx.Err(); err != nil {
+		return &CancelError{Op: op, Err: err}
+	}
+	return nil
+}
+
+// stopOnDone stops the Terraform context when the given context is done. The
+// first returned function must be called once the Terraform operations are
+// done, it waits until the running operation, if any, has been stopped. The
+// second one returns true if the Terraform context was stopped.
+func stopOnDone(ctx context.Context, tfCtx *terraform.Context) (func(), func() bool) {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	var stopped int32
+
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			atomic.StoreInt32(&stopped, 1)
+			// Stop blocks until the in-flight provider calls are done
+			tfCtx.Stop()
+		case <-done:
+		}
+	}()
+
+	stop := func() {
+		close(done)
+		<-exited
+	}
+	return stop, func() bool { return atomic.LoadInt32(&stopped) == 1 }
+}
+
+// persistState saves the current state with the state manager, if there is
+// one. The platform lock has to be held by the caller.
+func (p *Platform) persistState() error {
+	return p.writeState(p.State)
+}
+
+// setAppliedState makes the state returned by an apply the platform state and
+// saves it, even if the apply failed or was cancelled. The hooks save the state
+// when a resource changes but the final state may have other changes, such as
+// the output values.
+func (p *Platform) setAppliedState(state *State) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.State = state
+	return p.persistState()
+}
+
+// updateState makes the given state the platform state, with a new serial, and
+// saves it if it's different to the current state
+func (p *Platform) updateState(state *State) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if state == nil || state.Equal(p.State) {
+		return nil
+	}
+	p.State = state
+	p.serial++
+
+	return p.persistState()
+}
+
 // startMiddleware starts the Log Middleware to intercept the logs if it has not
 // been already started
 func (p *Platform) startMiddleware() {
@@ -109,81 +429,150 @@ func (p *Platform) startMiddleware() {
 	}
 }
 
-// newContext creates the Terraform context or configuration
-func (p *Platform) newContext(destroy bool) (*terraform.Context, error) {
-	cfg, err := p.config()
+// newContext creates the Terraform context or configuration. The given resource
+// instances are planned to be replaced, and the given hooks are used with the
+// platform hooks. It also returns the snapshot of the configuration used to
+// create the context.
+func (p *Platform) newContext(destroy bool, replace []string, hooks ...terraform.Hook) (*terraform.Context, *configload.Snapshot, error) {
+	cfg, snap, err := p.config()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
+	ctxOpts, err := p.contextOpts(cfg, destroy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if ctxOpts.State, err = taintedState(ctxOpts.State, replace); err != nil {
+		return nil, nil, err
+	}
+	ctxOpts.Hooks = p.hooks(hooks...)
+
+	ctx, err := p.newContextFromOpts(ctxOpts)
+	return ctx, snap, err
+}
+
+// contextOpts creates the ContextOpts with the current state and variables to
+// apply the given configuration
+func (p *Platform) contextOpts(cfg *configs.Config, destroy bool) (*terraform.ContextOpts, error) {
 	vars, err := p.variables(cfg.Module.Variables)
 	if err != nil {
 		return nil, err
 	}
 
+	targets, err := p.targets()
+	if err != nil {
+		return nil, err
+	}
+
 	// providerResolver := providers.ResolverFixed(p.Providers)
 	// provisioners := p.Provisioners
 
-	// Create ContextOpts with the current state and variables to apply
-	ctxOpts := terraform.ContextOpts{
+	return &terraform.ContextOpts{
 		Config:           cfg,
 		Destroy:          destroy,
 		State:            p.State,
+		Targets:          targets,
 		Variables:        vars,
 		ProviderResolver: providers.ResolverFixed(p.Providers),
 		Provisioners:     p.Provisioners,
 		Hooks:            p.Hooks,
+		Meta:             &terraform.ContextMeta{Env: p.workspace},
+	}, nil
+}
+
+// targets parses the target addresses
+func (p *Platform) targets() ([]addrs.Targetable, error) {
+	var targets []addrs.Targetable
+	for _, addr := range p.Targets {
+		target, diags := addrs.ParseTargetStr(addr)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("invalid target %q. %w", addr, newDiagnosticsError(diags, nil))
+		}
+		targets = append(targets, target.Subject)
 	}
+	return targets, nil
+}
 
-	ctx, diags := terraform.NewContext(&ctxOpts)
+// newContextFromOpts creates and validates the Terraform context with the given
+// options
+func (p *Platform) newContextFromOpts(ctxOpts *terraform.ContextOpts) (*terraform.Context, error) {
+	ctx, diags := terraform.NewContext(ctxOpts)
+	p.addWarnings(diags, codeDir(ctxOpts.Config))
 	if diags.HasErrors() {
-		return nil, diags.Err()
+		return nil, newDiagnosticsError(diags, ctxOpts.Config)
 	}
 
 	// Validate the context
-	if diags = ctx.Validate(); diags.HasErrors() {
-		return nil, diags.Err()
+	diags = ctx.Validate()
+	p.addWarnings(diags, codeDir(ctxOpts.Config))
+	if diags.HasErrors() {
+		return nil, newDiagnosticsError(diags, ctxOpts.Config)
 	}
 
 	return ctx, nil
 }
 
-func (p *Platform) config() (*configs.Config, error) {
+func (p *Platform) config() (*configs.Config, *configload.Snapshot, error) {
+	cfgPath, err := p.saveCodeToTempDir()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config, snap, diags := loadConfig(cfgPath)
+	p.addWarnings(diags, cfgPath)
+	if diags.HasErrors() {
+		return nil, nil, fmt.Errorf("failed to load the configuration. %w", &DiagnosticsError{Diags: diags, codeDir: cfgPath})
+	}
+
+	return config, snap, nil
+}
+
+// saveCodeToTempDir saves the code into a new temporal directory, returns the
+// path to such directory
+func (p *Platform) saveCodeToTempDir() (string, error) {
 	if len(p.Code) == 0 {
-		return nil, fmt.Errorf("no code to apply")
+		return "", ErrNoCode
 	}
 
 	// Get a temporal directory to save the infrastructure code
 	cfgPath, err := ioutil.TempDir("", ".terranova")
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	// defer os.RemoveAll(cfgPath)
 
 	if err := p.saveCode(cfgPath); err != nil {
-		return nil, err
+		return "", err
 	}
 
+	return cfgPath, nil
+}
+
+// loadConfig loads the configuration and a snapshot of it from the code in the
+// given directory
+func loadConfig(cfgPath string) (*configs.Config, *configload.Snapshot, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
 	loader, err := configload.NewLoader(&configload.Config{
 		ModulesDir: filepath.Join(cfgPath, "modules"),
 	})
 	if err != nil {
-		return nil, err
+		return nil, nil, diags.Append(err)
 	}
 
-	config, diags := loader.LoadConfig(cfgPath)
-	if diags.HasErrors() {
-		return nil, fmt.Errorf("failed to load the configuration. %s", diags.Error())
-	}
-
-	return config, nil
+	config, snap, hclDiags := loader.LoadConfigWithSnapshot(cfgPath)
+	return config, snap, diags.Append(hclDiags)
 }
 
 // Export save all the code to the given directory. The directory must exists
-// and there should be code to export.
+// and there should be code to export. The variables set with Var or BindVars
+// are saved in the `terraform.tfvars` file, if the code has this file they are
+// merged into it, replacing the variables with the same name.
 func (p *Platform) Export(dir string) error {
 	if len(p.Code) == 0 {
-		return fmt.Errorf("no code to export")
+		return ErrNoCode
 	}
 
 	if err := p.saveCode(dir); err != nil {
@@ -194,71 +583,62 @@ func (p *Platform) Export(dir string) error {
 		return nil
 	}
 
-	tfvarsFile, err := os.Create(filepath.Join(dir, "terraform.tfvars"))
+	content, err := p.exportVars()
 	if err != nil {
 		return err
 	}
-	defer tfvarsFile.Close()
+	if err := ioutil.WriteFile(filepath.Join(dir, defaultVarsFilename), content, 0644); err != nil {
+		return fmt.Errorf("failed to create the %s file. %w", defaultVarsFilename, err)
+	}
 
-	var totalErr string
-	w := bufio.NewWriter(tfvarsFile)
-	for name, value := range p.Vars {
-		_, err := w.WriteString(fmt.Sprintf("%s = \"%s\"\n", name, value))
-		if err != nil {
-			totalErr = fmt.Sprintf("%s\n\t%s", totalErr, err)
-		}
+	return nil
+}
+
+// exportVars returns the content of the `terraform.tfvars` file in the code,
+// if any, with the values of the variables set with Var or BindVars
+func (p *Platform) exportVars() ([]byte, error) {
+	file, hclDiags := hclwrite.ParseConfig([]byte(p.Code[defaultVarsFilename]), defaultVarsFilename, hcl.InitialPos)
+	if hclDiags.HasErrors() {
+		var diags tfdiags.Diagnostics
+		return nil, &DiagnosticsError{Diags: diags.Append(hclDiags)}
 	}
-	if err := w.Flush(); err != nil {
-		totalErr = fmt.Sprintf("%s\n\t%s", totalErr, err)
+
+	names := make([]string, 0, len(p.Vars))
+	for name := range p.Vars {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	if len(totalErr) != 0 {
-		return fmt.Errorf("Failed to create the terraform.tfvars file. Errors:%s", totalErr)
+	for _, name := range names {
+		val, err := ctyValue(p.Vars[name], cty.DynamicPseudoType)
+		if err == nil && !val.IsWhollyKnown() {
+			err = fmt.Errorf("the value is unknown")
+		}
+		if err != nil {
+			return nil, &VariableTypeError{Name: name, Type: cty.DynamicPseudoType, Err: err}
+		}
+		file.Body().SetAttributeValue(name, val)
 	}
 
-	return nil
+	return file.Bytes(), nil
 }
 
+// saveCode saves every code file to the given directory, which must exist
 func (p *Platform) saveCode(cfgPath string) error {
 	if _, err := os.Stat(cfgPath); os.IsNotExist(err) {
-		return fmt.Errorf("not found the directory to save the code. %s", err)
+		return fmt.Errorf("%w. %s", ErrCodeDirNotFound, err)
+	} else if err != nil {
+		return fmt.Errorf("failed to save the code. %w", err)
 	}
 
 	for filename, content := range p.Code {
 		cfgFileName := filepath.Join(cfgPath, filename)
-
-		cfgFileDir := filepath.Dir(cfgFileName)
-		if _, err := os.Stat(cfgFileDir); os.IsNotExist(err) {
-			os.MkdirAll(cfgFileDir, 0700)
+		if err := os.MkdirAll(filepath.Dir(cfgFileName), 0700); err != nil {
+			return fmt.Errorf("failed to save the code file %q. %w", filename, err)
 		}
-
-		cfgFile, err := os.Create(cfgFileName)
-		if err != nil {
-			return err
-		}
-		defer cfgFile.Close()
-
-		if _, err = io.Copy(cfgFile, strings.NewReader(content)); err != nil {
-			return err
+		if err := ioutil.WriteFile(cfgFileName, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to save the code file %q. %w", filename, err)
 		}
 	}
 	return nil
 }
-
-func (p *Platform) variables(v map[string]*configs.Variable) (terraform.InputValues, error) {
-	iv := make(terraform.InputValues)
-	for name, value := range p.Vars {
-		if _, declared := v[name]; !declared {
-			return iv, fmt.Errorf("variable %q is not declared in the code", name)
-		}
-
-		val := &terraform.InputValue{
-			Value:      cty.StringVal(fmt.Sprintf("%v", value)),
-			SourceType: terraform.ValueFromCaller,
-		}
-
-		iv[name] = val
-	}
-
-	return iv, nil
-}
@@ -17,6 +17,8 @@ limitations under the License.
 package terranova
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -24,10 +26,13 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/terraform/addrs"
 	"github.com/hashicorp/terraform/configs/configschema"
+	"github.com/hashicorp/terraform/plans"
 	"github.com/hashicorp/terraform/providers"
+	"github.com/hashicorp/terraform/states/statefile"
 	"github.com/hashicorp/terraform/terraform"
 	"github.com/zclconf/go-cty/cty"
 )
@@ -103,6 +108,56 @@ func TestPlatform_AddFile_Export(t *testing.T) {
 	testMainCodeOrder(t)
 }
 
+func TestPlatform_Export_vars(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", ".terranova_test")
+	if err != nil {
+		t.Fatalf("Failed to create temporal directory. %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// The bound variables are merged into the terraform.tfvars file of the code
+	p := NewPlatform(typedVariablesCode).
+		AddFile(defaultVarsFilename, "# Default values\nsubnets = [\"10.0.1.0/24\"]\nreplicas = 1\n").
+		BindVars(map[string]interface{}{
+			"tags":     map[string]string{"env": "dev", "quote": `"quoted"`},
+			"replicas": 3,
+		})
+	if err := p.Export(tmpDir); err != nil {
+		t.Fatalf("Platform.Export() error = %v", err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(tmpDir, defaultVarsFilename))
+	if err != nil {
+		t.Fatalf("Platform.Export() failed reading the variables file. %s", err)
+	}
+	if !strings.HasPrefix(string(content), "# Default values\n") {
+		t.Errorf("Platform.Export() did not keep the variables file content: %s", content)
+	}
+	iv, diags := parseVarFile(varFile{name: defaultVarsFilename, content: string(content)})
+	if diags.HasErrors() {
+		t.Fatalf("Platform.Export() saved an invalid variables file. %s\n%s", diags.Err(), content)
+	}
+	want := map[string]cty.Value{
+		"subnets":  cty.TupleVal([]cty.Value{cty.StringVal("10.0.1.0/24")}),
+		"tags":     cty.ObjectVal(map[string]cty.Value{"env": cty.StringVal("dev"), "quote": cty.StringVal(`"quoted"`)}),
+		"replicas": cty.NumberIntVal(3),
+	}
+	if len(iv) != len(want) {
+		t.Errorf("Platform.Export() saved %d variables, want %d\n%s", len(iv), len(want), content)
+	}
+	for name, val := range want {
+		if got := iv[name]; got == nil || !got.Value.RawEquals(val) {
+			t.Errorf("Platform.Export() saved %s = %#v, want %#v", name, got, val)
+		}
+	}
+
+	// The code can't have an invalid terraform.tfvars file
+	p = NewPlatform(typedVariablesCode).AddFile(defaultVarsFilename, "subnets = [\n").Var("replicas", 3)
+	var diagsErr *DiagnosticsError
+	if err := p.Export(tmpDir); !errors.As(err, &diagsErr) {
+		t.Errorf("Platform.Export() error = %v, want %T", err, diagsErr)
+	}
+}
+
 // Special test case, to test the order of adding a main code with empty file name and 'main.tf' file name
 func testMainCodeOrder(t *testing.T) {
 	// "" -> "main.tf"
@@ -193,6 +248,214 @@ func TestPlatform_Plan(t *testing.T) {
 	}
 }
 
+func TestPlatform_ApplyContext(t *testing.T) {
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name           string
+		platformFields platformFields
+		ctx            context.Context
+		timeout        time.Duration
+		wantCancel     bool
+	}{
+		{"not cancelled", testsPlatformsFields["test instance"], context.Background(), 0, false},
+		{"cancelled before start", testsPlatformsFields["test instance"], cancelledCtx, 0, true},
+		{"deadline during apply", testsPlatformsFields["slow test instance"], context.Background(), 50 * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPlatformForTest(tt.platformFields)
+
+			ctx := tt.ctx
+			if tt.timeout != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			err := p.ApplyContext(ctx, false)
+			var cancelErr *CancelError
+			if gotCancel := errors.As(err, &cancelErr); gotCancel != tt.wantCancel {
+				t.Errorf("Platform.ApplyContext() error = %v, wantCancel %v", err, tt.wantCancel)
+				return
+			}
+			if !tt.wantCancel && err != nil {
+				t.Errorf("Platform.ApplyContext() unexpected error = %v", err)
+			}
+			if tt.wantCancel && !errors.Is(err, ctx.Err()) {
+				t.Errorf("Platform.ApplyContext() error = %v, want to wrap %v", err, ctx.Err())
+			}
+		})
+	}
+}
+
+func TestPlatform_ApplyContext_cancelledAfterApply(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The context is cancelled when the final state is saved, after Terraform
+	// applied the changes
+	backend := &cancellingBackend{MemoryBackend: NewMemoryBackend()}
+	p, err := NewPlatform("output \"foo\" {\n  value = \"bar\"\n}\n").PersistStateTo(backend)
+	if err != nil {
+		t.Fatalf("Platform.PersistStateTo() error = %v", err)
+	}
+	backend.cancel = cancel
+
+	if err := p.ApplyContext(ctx, false); err != nil {
+		t.Errorf("Platform.ApplyContext() error = %v, want no error", err)
+	}
+	if ctx.Err() == nil {
+		t.Errorf("the context was not cancelled after the apply")
+	}
+	if got, err := p.OutputValueAsString("foo"); err != nil || got != "bar" {
+		t.Errorf("Platform.OutputValueAsString() = %q, %v, want \"bar\"", got, err)
+	}
+}
+
+// cancellingBackend is a StateBackend calling cancel, if set, when the state
+// is saved
+type cancellingBackend struct {
+	*MemoryBackend
+	cancel context.CancelFunc
+}
+
+func (b *cancellingBackend) WriteState(workspace string, sf *statefile.File) error {
+	if b.cancel != nil {
+		b.cancel()
+	}
+	return b.MemoryBackend.WriteState(workspace, sf)
+}
+
+func TestPlatform_PlanContext(t *testing.T) {
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name           string
+		platformFields platformFields
+		ctx            context.Context
+		wantCancel     bool
+	}{
+		{"not cancelled", testsPlatformsFields["test instance"], context.Background(), false},
+		{"cancelled before start", testsPlatformsFields["test instance"], cancelledCtx, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPlatformForTest(tt.platformFields)
+
+			plan, err := p.PlanContext(tt.ctx, false)
+			var cancelErr *CancelError
+			if gotCancel := errors.As(err, &cancelErr); gotCancel != tt.wantCancel {
+				t.Errorf("Platform.PlanContext() error = %v, wantCancel %v", err, tt.wantCancel)
+				return
+			}
+			if !tt.wantCancel && plan == nil {
+				t.Errorf("Platform.PlanContext() returned a nil plan, error = %v", err)
+			}
+		})
+	}
+}
+
+func TestPlatform_ApplyPlan(t *testing.T) {
+	tests := []struct {
+		name           string
+		platformFields platformFields
+		beforeApply    func(p *Platform, plan *plans.Plan) error
+		wantErr        bool
+	}{
+		{"plan as is", testsPlatformsFields["test instance"], nil, false},
+		{"state changed", testsPlatformsFields["test instance"], func(p *Platform, plan *plans.Plan) error { return p.Apply(false) }, true},
+		{"plan applied", testsPlatformsFields["test instance"], func(p *Platform, plan *plans.Plan) error { return p.ApplyPlan(plan) }, true},
+		{"plan discarded", testsPlatformsFields["test instance"], func(p *Platform, plan *plans.Plan) error {
+			p.DiscardPlan(plan)
+			return nil
+		}, true},
+		{"state replaced", testsPlatformsFields["test instance"], func(p *Platform, plan *plans.Plan) error {
+			_, err := p.ReadState(strings.NewReader(strings.Replace(emptyStateStr, `"serial": 0`, `"serial": 3`, 1)))
+			return err
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPlatformForTest(tt.platformFields)
+
+			plan, err := p.Plan(false)
+			if err != nil {
+				t.Fatalf("Platform.Plan() error = %v", err)
+			}
+			if tt.beforeApply != nil {
+				if err := tt.beforeApply(p, plan); err != nil {
+					t.Fatalf("failed to change the platform before applying the plan. %s", err)
+				}
+			}
+
+			err = p.ApplyPlan(plan)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Platform.ApplyPlan() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if got := p.Stats(); got.Add != 1 {
+				t.Errorf("Platform.ApplyPlan() stats = %v, want 1 added", got)
+			}
+			if got := len(p.State.RootModule().Resources); got != 1 {
+				t.Errorf("Platform.ApplyPlan() state has %d resources, want 1", got)
+			}
+		})
+	}
+
+	if err := NewPlatform("").ApplyPlan(&plans.Plan{}); err == nil {
+		t.Errorf("Platform.ApplyPlan() expected an error applying an unknown plan")
+	}
+}
+
+func TestPlatform_Target(t *testing.T) {
+	tests := []struct {
+		name        string
+		targets     []string
+		wantChanges int
+		wantErr     bool
+	}{
+		{"no targets", nil, 2, false},
+		{"one resource", []string{"test_instance.foo"}, 1, false},
+		{"all resources", []string{"test_instance.foo", "test_instance.bar"}, 2, false},
+		{"invalid target", []string{"test_instance"}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPlatformForTest(platformFields{
+				Code: testInstancesCode("foo", "bar"),
+				Providers: map[string]providers.Factory{
+					"test": providers.FactoryFixed(NewMockProvider(nil, "test", testSimpleSchema())),
+				},
+			}).Target(tt.targets...)
+
+			plan, err := p.Plan(false)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Platform.Plan() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if got := len(plan.Changes.Resources); got != tt.wantChanges {
+				t.Errorf("Platform.Plan() planned %d changes, want %d", got, tt.wantChanges)
+			}
+
+			if err := p.Apply(false); err != nil {
+				t.Fatalf("Platform.Apply() error = %v", err)
+			}
+			if got := len(p.State.RootModule().Resources); got != tt.wantChanges {
+				t.Errorf("Platform.Apply() state has %d resources, want %d", got, tt.wantChanges)
+			}
+		})
+	}
+}
+
 func newPlatformForTest(tt platformFields) *Platform {
 	p := NewPlatform(tt.Code, tt.Hooks...).BindVars(tt.Vars)
 	if tt.State != nil {
@@ -258,6 +521,18 @@ func NewMockProvider(t *testing.T, name string, schema *terraform.ProviderSchema
 	return p
 }
 
+// newSlowMockProvider creates a MockProvider that takes the given time to
+// apply any change
+func newSlowMockProvider(d time.Duration, schema *terraform.ProviderSchema) *terraform.MockProvider {
+	p := NewMockProvider(nil, "test", schema)
+	applyFn := p.ApplyResourceChangeFn
+	p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) providers.ApplyResourceChangeResponse {
+		time.Sleep(d)
+		return applyFn(req)
+	}
+	return p
+}
+
 type platformFields struct {
 	Code      string
 	CodeFiles map[string]string
@@ -275,6 +550,12 @@ var testsPlatformsFields = map[string]platformFields{
 			"test": providers.FactoryFixed(NewMockProvider(nil, "test", testSimpleSchema())),
 		},
 	},
+	"slow test instance": platformFields{
+		Code: testSimpleInstance,
+		Providers: map[string]providers.Factory{
+			"test": providers.FactoryFixed(newSlowMockProvider(200*time.Millisecond, testSimpleSchema())),
+		},
+	},
 	"test instance with data source": platformFields{
 		Code: testInstanceWithDataSource,
 		Providers: map[string]providers.Factory{
@@ -0,0 +1,188 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/configs"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/hashicorp/terraform/tfdiags"
+)
+
+// Validate checks the code, the variables and the providers of the platform
+// without refreshing or changing anything. It returns all the errors and
+// warnings found, an empty list means the platform is valid.
+func (p *Platform) Validate() Diagnostics {
+	p.resetWarnings()
+
+	diags := p.validate()
+	p.addWarningDiagnostics(diags)
+
+	return diags
+}
+
+func (p *Platform) validate() Diagnostics {
+	var diags tfdiags.Diagnostics
+
+	cfgPath, err := p.saveCodeToTempDir()
+	if err != nil {
+		return newDiagnostics(diags.Append(err), "")
+	}
+
+	cfg, _, loadDiags := loadConfig(cfgPath)
+	diags = diags.Append(loadDiags)
+	if diags.HasErrors() {
+		return newDiagnostics(diags, cfgPath)
+	}
+
+	diags = diags.Append(p.validateVariables(cfg))
+	diags = diags.Append(p.validateProviders(cfg))
+	if diags.HasErrors() {
+		return newDiagnostics(diags, cfgPath)
+	}
+
+	ctxOpts, err := p.contextOpts(cfg, false)
+	if err != nil {
+		return newDiagnostics(diags.Append(err), cfgPath)
+	}
+
+	tfCtx, ctxDiags := terraform.NewContext(ctxOpts)
+	diags = diags.Append(ctxDiags)
+	if diags.HasErrors() {
+		return newDiagnostics(diags, cfgPath)
+	}
+
+	diags = diags.Append(tfCtx.Validate())
+
+	return newDiagnostics(diags, cfgPath)
+}
+
+// validateVariables checks every bound variable is declared in the root module
+// and every required variable has a value from any of the variable sources
+func (p *Platform) validateVariables(cfg *configs.Config) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+
+	values := map[string]bool{}
+	for _, source := range p.variableSources() {
+		iv, err := source.Values(cfg.Module.Variables)
+		var diagsErr *DiagnosticsError
+		switch {
+		case errors.As(err, &diagsErr):
+			diags = diags.Append(diagsErr.Diags)
+		case err != nil:
+			diags = diags.Append(err)
+		}
+		for name := range iv {
+			values[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(p.Vars))
+	for name := range p.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, declared := cfg.Module.Variables[name]; !declared {
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Value for undeclared variable",
+				fmt.Sprintf("The variable %q is bound to the platform but it is not declared in the code.", name),
+			))
+		}
+	}
+
+	for _, v := range sortedVariables(cfg.Module.Variables) {
+		if values[v.Name] || !v.Required() {
+			continue
+		}
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "No value for required variable",
+			Detail:   fmt.Sprintf("The variable %q is required but it has no value, set its value with Var, BindVars, VarFile or VarSources.", v.Name),
+			Subject:  v.DeclRange.Ptr(),
+		})
+	}
+
+	return diags
+}
+
+// validateProviders checks every provider used in the code, in all the modules,
+// was added to the platform
+func (p *Platform) validateProviders(cfg *configs.Config) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+
+	reported := map[addrs.Provider]bool{}
+	check := func(provider addrs.Provider, usedBy string, rng hcl.Range) {
+		if _, ok := p.Providers[provider]; ok || reported[provider] {
+			return
+		}
+		reported[provider] = true
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Provider not added to the platform",
+			Detail:   fmt.Sprintf("The provider %q is used by %s but it was not added to the platform, use AddProvider to add it.", provider.Type, usedBy),
+			Subject:  rng.Ptr(),
+		})
+	}
+
+	cfg.DeepEach(func(c *configs.Config) {
+		for _, pc := range sortedProviderConfigs(c.Module.ProviderConfigs) {
+			check(addrs.NewLegacyProvider(pc.Name), fmt.Sprintf("the provider block %q", pc.Name), pc.DeclRange)
+		}
+		for _, r := range sortedResources(c.Module.ManagedResources, c.Module.DataResources) {
+			check(r.ProviderConfigAddr().Type, r.Addr().String(), r.DeclRange)
+		}
+	})
+
+	return diags
+}
+
+func sortedVariables(m map[string]*configs.Variable) []*configs.Variable {
+	vars := make([]*configs.Variable, 0, len(m))
+	for _, v := range m {
+		vars = append(vars, v)
+	}
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+	return vars
+}
+
+func sortedProviderConfigs(m map[string]*configs.Provider) []*configs.Provider {
+	pcs := make([]*configs.Provider, 0, len(m))
+	for _, pc := range m {
+		pcs = append(pcs, pc)
+	}
+	sort.Slice(pcs, func(i, j int) bool { return pcs[i].Name < pcs[j].Name })
+	return pcs
+}
+
+func sortedResources(maps ...map[string]*configs.Resource) []*configs.Resource {
+	var rs []*configs.Resource
+	for _, m := range maps {
+		for _, r := range m {
+			rs = append(rs, r)
+		}
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].Addr().String() < rs[j].Addr().String() })
+	return rs
+}
@@ -0,0 +1,166 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPlatform_Validate(t *testing.T) {
+	type wantDiag struct {
+		severity Severity
+		summary  string
+		filename string
+		line     int
+	}
+	tests := []struct {
+		name           string
+		platformFields platformFields
+		want           []wantDiag
+	}{
+		{"valid", testsPlatformsFields["test instance"], nil},
+		{"no code", platformFields{}, []wantDiag{
+			{SeverityError, "no code", "", 0},
+		}},
+		{"syntax error", platformFields{
+			CodeFiles: map[string]string{"bad.tf": "resource \"null_resource\" \"foo\" {\n  triggers = \n}\n"},
+		}, []wantDiag{
+			{SeverityError, "Invalid expression", "bad.tf", 2},
+		}},
+		{"undeclared and required variables", platformFields{
+			Code: "variable \"required\" {}\n",
+			Vars: map[string]interface{}{"undeclared": 1},
+		}, []wantDiag{
+			{SeverityError, "Value for undeclared variable", "", 0},
+			{SeverityError, "No value for required variable", "main.tf", 1},
+		}},
+		{"provider not added", platformFields{
+			Code: testSimpleInstance,
+		}, []wantDiag{
+			{SeverityError, "Provider not added to the platform", "main.tf", 2},
+		}},
+		{"invalid reference", platformFields{
+			Code: "output \"foo\" {\n  value = null_resource.missing.id\n}\n",
+		}, []wantDiag{
+			{SeverityError, "Reference to undeclared resource", "main.tf", 2},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPlatformForTest(tt.platformFields)
+
+			got := p.Validate()
+			if len(got) != len(tt.want) {
+				t.Fatalf("Platform.Validate() = %v, want %d diagnostics", got, len(tt.want))
+			}
+			if got.HasErrors() != (len(tt.want) != 0) {
+				t.Errorf("Diagnostics.HasErrors() = %v, diagnostics %v", got.HasErrors(), got)
+			}
+			for i, want := range tt.want {
+				d := got[i]
+				if d.Severity != want.severity || d.Summary != want.summary {
+					t.Errorf("Platform.Validate()[%d] = %v, want %s %q", i, d, want.severity, want.summary)
+				}
+				if len(want.filename) == 0 {
+					if d.Subject != nil {
+						t.Errorf("Platform.Validate()[%d] subject = %v, want none", i, d.Subject)
+					}
+					continue
+				}
+				if d.Subject == nil || d.Subject.Filename != want.filename || d.Subject.Start.Line != want.line {
+					t.Errorf("Platform.Validate()[%d] subject = %v, want %s:%d", i, d.Subject, want.filename, want.line)
+				}
+			}
+		})
+	}
+}
+
+func TestPlatform_Validate_requiredVariables(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", ".terranova_validate_test")
+	if err != nil {
+		t.Fatalf("Failed to create temporal directory. %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	if err := ioutil.WriteFile(configFile, []byte("required: value\n"), 0644); err != nil {
+		t.Fatalf("Failed to write the config file. %s", err)
+	}
+
+	tests := []struct {
+		name        string
+		setup       func(p *Platform) *Platform
+		wantSummary string
+	}{
+		{"no value", func(p *Platform) *Platform {
+			return p
+		}, "No value for required variable"},
+		{"bound", func(p *Platform) *Platform {
+			return p.Var("required", "value")
+		}, ""},
+		{"terraform.tfvars", func(p *Platform) *Platform {
+			return p.AddFile("terraform.tfvars", "required = \"value\"\n")
+		}, ""},
+		{"auto tfvars", func(p *Platform) *Platform {
+			return p.AddFile("required.auto.tfvars.json", `{"required": "value"}`)
+		}, ""},
+		{"variables file", func(p *Platform) *Platform {
+			return p.VarFileContent("required.tfvars", "required = \"value\"\n")
+		}, ""},
+		{"variables file without the variable", func(p *Platform) *Platform {
+			return p.VarFileContent("other.tfvars", "other = \"value\"\n")
+		}, "No value for required variable"},
+		{"invalid variables file", func(p *Platform) *Platform {
+			return p.VarFileContent("required.tfvars", "required = \n")
+		}, "Invalid expression"},
+		{"environment variable", func(p *Platform) *Platform {
+			setEnvForTest(t, "TF_VAR_required", "value")
+			return p.VarSources(EnvVariables())
+		}, ""},
+		{"config file", func(p *Platform) *Platform {
+			return p.VarSources(ConfigFileVariables(configFile))
+		}, ""},
+		{"missing config file", func(p *Platform) *Platform {
+			return p.VarSources(ConfigFileVariables(filepath.Join(tmpDir, "missing.yaml")))
+		}, "open " + filepath.Join(tmpDir, "missing.yaml") + ": no such file or directory"},
+		{"map", func(p *Platform) *Platform {
+			return p.VarSources(MapVariables(map[string]interface{}{"required": "value"}))
+		}, ""},
+		{"invalid map value", func(p *Platform) *Platform {
+			return p.VarSources(MapVariables(map[string]interface{}{"required": make(chan int)}))
+		}, `invalid value for variable "required"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.setup(NewPlatform("variable \"required\" {}\n"))
+
+			got := p.Validate()
+			if len(tt.wantSummary) == 0 {
+				if got.HasErrors() {
+					t.Errorf("Platform.Validate() = %v, want no errors", got)
+				}
+				return
+			}
+			if len(got) == 0 || got[0].Severity != SeverityError || !strings.HasPrefix(got[0].Summary, tt.wantSummary) {
+				t.Errorf("Platform.Validate() = %v, want error %q", got, tt.wantSummary)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/terraform/configs"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/hashicorp/terraform/tfdiags"
+	ctyyaml "github.com/zclconf/go-cty-yaml"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// envVarPrefix is the prefix of the environment variables with the value of a
+// variable, like in the Terraform CLI
+const envVarPrefix = "TF_VAR_"
+
+// VariableSource is a source of values for the variables declared in the code
+type VariableSource interface {
+	// Values returns the values of the variables in the source. The variables
+	// declared in the code are given to know how to parse the raw values.
+	Values(declared map[string]*configs.Variable) (terraform.InputValues, error)
+}
+
+// VarSources adds sources of values for the variables. The sources added later
+// take precedence over the previous ones, and all of them have less precedence
+// than the variables files and the variables set with Var or BindVars, like
+// the environment variables in the Terraform CLI.
+func (p *Platform) VarSources(sources ...VariableSource) *Platform {
+	p.varSources = append(p.varSources, sources...)
+	return p
+}
+
+// EnvVariables returns a source with the values of the declared variables in
+// the `TF_VAR_<name>` environment variables. Like the Terraform CLI, the value
+// is a string for the variables of type string or without a type, otherwise
+// it's parsed as an HCL expression, i.e. `["a", "b"]` or `{ env = "dev" }`.
+func EnvVariables() VariableSource {
+	return envVariables{}
+}
+
+type envVariables struct{}
+
+func (envVariables) Values(declared map[string]*configs.Variable) (terraform.InputValues, error) {
+	iv := make(terraform.InputValues)
+	var diags tfdiags.Diagnostics
+
+	for _, env := range os.Environ() {
+		if !strings.HasPrefix(env, envVarPrefix) {
+			continue
+		}
+		eq := strings.Index(env, "=")
+		name, raw := env[len(envVarPrefix):eq], env[eq+1:]
+		cfg, ok := declared[name]
+		if !ok {
+			continue
+		}
+
+		val, hclDiags := cfg.ParsingMode.Parse(name, raw)
+		diags = diags.Append(hclDiags)
+		iv[name] = &terraform.InputValue{
+			Value:      val,
+			SourceType: terraform.ValueFromEnvVar,
+		}
+	}
+	if diags.HasErrors() {
+		return nil, &DiagnosticsError{Diags: diags}
+	}
+
+	return iv, nil
+}
+
+// ConfigFileVariables returns a source with the values of the variables in the
+// given YAML or JSON file, which has an object with the variable values. The
+// file is read every time the variables are needed.
+func ConfigFileVariables(filename string) VariableSource {
+	return configFileVariables{filename: filename}
+}
+
+type configFileVariables struct {
+	filename string
+}
+
+func (f configFileVariables) Values(map[string]*configs.Variable) (terraform.InputValues, error) {
+	content, err := ioutil.ReadFile(f.filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(strings.TrimSpace(string(content))) == 0 {
+		return terraform.InputValues{}, nil
+	}
+
+	ty, err := ctyyaml.Standard.ImpliedType(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the variables file %q. %s", f.filename, err)
+	}
+	val, err := ctyyaml.Standard.Unmarshal(content, ty)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the variables file %q. %s", f.filename, err)
+	}
+	if val.IsNull() {
+		return terraform.InputValues{}, nil
+	}
+	if !val.Type().IsObjectType() {
+		return nil, fmt.Errorf("the variables file %q has to contain an object with the variable values", f.filename)
+	}
+
+	iv := make(terraform.InputValues)
+	for name, value := range val.AsValueMap() {
+		iv[name] = &terraform.InputValue{
+			Value:       value,
+			SourceType:  terraform.ValueFromNamedFile,
+			SourceRange: tfdiags.SourceRange{Filename: f.filename},
+		}
+	}
+
+	return iv, nil
+}
+
+// MapVariables returns a source with the values of the variables in the given
+// map. The values are Go values, converted to the type declared in the code,
+// or cty values. Var and BindVars are a map source with the highest
+// precedence.
+func MapVariables(vars map[string]interface{}) VariableSource {
+	return mapVariables(vars)
+}
+
+type mapVariables map[string]interface{}
+
+func (m mapVariables) Values(declared map[string]*configs.Variable) (terraform.InputValues, error) {
+	iv := make(terraform.InputValues, len(m))
+	for name, value := range m {
+		ty := cty.DynamicPseudoType
+		if cfg, ok := declared[name]; ok {
+			ty = cfg.Type
+		}
+		val, err := ctyValue(value, ty)
+		if err != nil {
+			return nil, &VariableTypeError{Name: name, Type: ty, Err: err}
+		}
+		iv[name] = &terraform.InputValue{
+			Value:      val,
+			SourceType: terraform.ValueFromCaller,
+		}
+	}
+
+	return iv, nil
+}
@@ -0,0 +1,162 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlatform_VarSources(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", ".terranova_varsources_test")
+	if err != nil {
+		t.Fatalf("Failed to create temporal directory. %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	setEnvForTest(t, "TF_VAR_subnets", `["10.0.1.0/24", "10.0.2.0/24"]`)
+	setEnvForTest(t, "TF_VAR_tags", `{ env = "env" }`)
+	setEnvForTest(t, "TF_VAR_replicas", "1")
+	setEnvForTest(t, "TF_VAR_undeclared", "ignored")
+
+	filename := filepath.Join(tmpDir, "config.yaml")
+	if err := ioutil.WriteFile(filename, []byte("tags:\n  env: yaml\n  team: infra\nreplicas: 2\n"), 0644); err != nil {
+		t.Fatalf("Failed to create the config file. %s", err)
+	}
+
+	p := NewPlatform(typedVariablesCode).VarSources(
+		EnvVariables(),
+		ConfigFileVariables(filename),
+		MapVariables(map[string]interface{}{"replicas": 3}),
+	)
+	assertOutputs(t, p, map[string]string{
+		"subnets":  "10.0.1.0/24,10.0.2.0/24",
+		"tags":     "env=yaml,team=infra",
+		"replicas": "6",
+	})
+
+	// The variables files and Var take precedence over the sources
+	p.VarFileContent("prod.tfvars", "tags = { env = \"prod\" }\n").Var("replicas", 4)
+	assertOutputs(t, p, map[string]string{
+		"subnets":  "10.0.1.0/24,10.0.2.0/24",
+		"tags":     "env=prod",
+		"replicas": "8",
+	})
+}
+
+func TestVariableSources_errors(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", ".terranova_varsources_test")
+	if err != nil {
+		t.Fatalf("Failed to create temporal directory. %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	configFile := func(name, content string) VariableSource {
+		filename := filepath.Join(tmpDir, name)
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create the config file. %s", err)
+		}
+		return ConfigFileVariables(filename)
+	}
+
+	var (
+		diagsErr      *DiagnosticsError
+		typeErr       *VariableTypeError
+		undeclaredErr *UndeclaredVariableError
+	)
+
+	tests := []struct {
+		name        string
+		env         string
+		source      VariableSource
+		target      interface{}
+		wantWarning string
+	}{
+		{"invalid env expression", `["10.0.1.0/24"`, EnvVariables(), &diagsErr, ""},
+		{"invalid env type", `"10.0.1.0/24"`, EnvVariables(), &typeErr, ""},
+		{"json config", "", configFile("config.json", `{"subnets": ["10.0.1.0/24"], "tags": {}, "replicas": 1}`), nil, ""},
+		{"empty config", "", configFile("empty.yaml", ""), nil, ""},
+		{"invalid config type", "", configFile("type.yaml", "subnets: 10.0.1.0/24\n"), &typeErr, ""},
+		{"undeclared config variable", "", configFile("undeclared.yaml", "zones: [a, b]\n"), nil, "Value for undeclared variable"},
+		{"undeclared map variable", "", MapVariables(map[string]interface{}{"zones": "a"}), &undeclaredErr, ""},
+		{"invalid map type", "", MapVariables(map[string]interface{}{"replicas": "many"}), &typeErr, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.env) != 0 {
+				setEnvForTest(t, "TF_VAR_subnets", tt.env)
+			}
+			p := NewPlatform(typedVariablesCode).
+				BindVars(map[string]interface{}{"subnets": []string{}, "tags": map[string]string{}, "replicas": 1}).
+				VarSources(tt.source)
+
+			_, err := p.Plan(false)
+			if tt.target == nil && err != nil {
+				t.Fatalf("Platform.Plan() error = %v", err)
+			}
+			if tt.target != nil && !errors.As(err, tt.target) {
+				t.Fatalf("Platform.Plan() error = %v, want %T", err, tt.target)
+			}
+			if len(tt.wantWarning) == 0 {
+				return
+			}
+			if warnings := p.Warnings(); len(warnings) != 1 || warnings[0].Summary != tt.wantWarning {
+				t.Errorf("Platform.Warnings() = %v, want %q", warnings, tt.wantWarning)
+			}
+		})
+	}
+
+	for _, source := range []VariableSource{
+		ConfigFileVariables(filepath.Join(tmpDir, "missing.yaml")),
+		configFile("list.yaml", "- a\n- b\n"),
+		configFile("invalid.yaml", "tags: {\n"),
+	} {
+		if _, err := source.Values(nil); err == nil {
+			t.Errorf("ConfigFileVariables().Values() error = nil, want an error")
+		}
+	}
+}
+
+// setEnvForTest sets the environment variable until the end of the test
+func setEnvForTest(t *testing.T, name, value string) {
+	t.Helper()
+	prev, ok := os.LookupEnv(name)
+	os.Setenv(name, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(name, prev)
+		} else {
+			os.Unsetenv(name)
+		}
+	})
+}
+
+// assertOutputs applies the platform and verifies its output values
+func assertOutputs(t *testing.T, p *Platform, want map[string]string) {
+	t.Helper()
+	if err := p.Apply(false); err != nil {
+		t.Fatalf("Platform.Apply() error = %v", err)
+	}
+	for name, value := range want {
+		if got, err := p.OutputValueAsString(name); err != nil || got != value {
+			t.Errorf("Platform.OutputValueAsString(%q) = %q, %v, want %q", name, got, err, value)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	hcljson "github.com/hashicorp/hcl/v2/json"
+	"github.com/hashicorp/terraform/configs"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/hashicorp/terraform/tfdiags"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// defaultVarsFilename is the variables file in the code loaded automatically,
+// like the Terraform CLI does, as well as its JSON version and the files with
+// extension `.auto.tfvars` or `.auto.tfvars.json`
+const defaultVarsFilename = "terraform.tfvars"
+
+// varFile is a variables file in the code or added to the platform
+type varFile struct {
+	name       string
+	content    string
+	sourceType terraform.ValueSourceType
+}
+
+// Values returns the values of the variables in the file
+func (f varFile) Values(map[string]*configs.Variable) (terraform.InputValues, error) {
+	iv, diags := parseVarFile(f)
+	if diags.HasErrors() {
+		return nil, &DiagnosticsError{Diags: diags}
+	}
+	return iv, nil
+}
+
+// VarFile loads the variables in the given .tfvars file, or .tfvars.json file
+// if its extension is `.json`. Like `-var-file` in the Terraform CLI, the
+// variables files take precedence over the `terraform.tfvars` and
+// `*.auto.tfvars` files in the code, and over the variables files added
+// before. The variables set with Var or BindVars take precedence over all the
+// variables files.
+func (p *Platform) VarFile(filename string) (*Platform, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return p, err
+	}
+	return p.VarFileContent(filename, string(content)), nil
+}
+
+// VarFileContent is like VarFile but with the content of the variables file.
+// The name is used to identify the file in the diagnostics, and it's parsed as
+// JSON if it has extension `.json`.
+func (p *Platform) VarFileContent(name, content string) *Platform {
+	p.varFiles = append(p.varFiles, varFile{name: name, content: content, sourceType: terraform.ValueFromNamedFile})
+	return p
+}
+
+// variables returns the input values of the variables from every source, in
+// order of precedence, converted to the type declared in the code:
+//
+//  1. The sources added with VarSources, in the order they were added
+//  2. The `terraform.tfvars` and `*.auto.tfvars` files in the code
+//  3. The variables files added with VarFile or VarFileContent
+//  4. The variables set with Var or BindVars
+//
+// Like the Terraform CLI, a value from a variables file for a variable not
+// declared in the code is ignored with a warning, and from an environment
+// variable it's just ignored.
+func (p *Platform) variables(v map[string]*configs.Variable) (terraform.InputValues, error) {
+	iv := make(terraform.InputValues)
+	var diags tfdiags.Diagnostics
+	for _, source := range p.variableSources() {
+		values, err := source.Values(v)
+		if err != nil {
+			return iv, err
+		}
+
+		for name, value := range values {
+			cfg, declared := v[name]
+			if !declared {
+				switch value.SourceType {
+				case terraform.ValueFromEnvVar:
+					// Ignored, the environment may be shared by several platforms
+				case terraform.ValueFromAutoFile, terraform.ValueFromNamedFile:
+					subject := value.SourceRange.ToHCL()
+					diags = diags.Append(&hcl.Diagnostic{
+						Severity: hcl.DiagWarning,
+						Summary:  "Value for undeclared variable",
+						Detail:   fmt.Sprintf("The root module does not declare a variable named %q but a value was found in file %q. To use this value, add a \"variable\" block to the configuration.", name, value.SourceRange.Filename),
+						Subject:  &subject,
+					})
+				default:
+					return iv, &UndeclaredVariableError{Name: name}
+				}
+				continue
+			}
+
+			val, err := ctyValue(value.Value, cfg.Type)
+			if err != nil {
+				return iv, &VariableTypeError{Name: name, Type: cfg.Type, Err: err}
+			}
+			value.Value = val
+			iv[name] = value
+		}
+	}
+	p.addWarnings(diags, "")
+
+	return iv, nil
+}
+
+// variableSources returns every source of values for the variables, in order of
+// precedence
+func (p *Platform) variableSources() []VariableSource {
+	sources := append([]VariableSource{}, p.varSources...)
+	for _, file := range p.autoVarFiles() {
+		sources = append(sources, file)
+	}
+	for _, file := range p.varFiles {
+		sources = append(sources, file)
+	}
+	return append(sources, MapVariables(p.Vars))
+}
+
+// autoVarFiles returns the variables files in the root directory of the code
+// loaded automatically, in the same order as the Terraform CLI
+func (p *Platform) autoVarFiles() []varFile {
+	var files, autoFiles []varFile
+	for name, content := range p.Code {
+		if filepath.Dir(name) != "." {
+			continue
+		}
+		file := varFile{name: name, content: content, sourceType: terraform.ValueFromAutoFile}
+		switch {
+		case name == defaultVarsFilename:
+			files = append([]varFile{file}, files...)
+		case name == defaultVarsFilename+".json":
+			files = append(files, file)
+		case strings.HasSuffix(name, ".auto.tfvars") || strings.HasSuffix(name, ".auto.tfvars.json"):
+			autoFiles = append(autoFiles, file)
+		}
+	}
+	sort.Slice(autoFiles, func(i, j int) bool { return autoFiles[i].name < autoFiles[j].name })
+
+	return append(files, autoFiles...)
+}
+
+// parseVarFile returns the input values of the variables in the given
+// variables file
+func parseVarFile(file varFile) (terraform.InputValues, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	var f *hcl.File
+	var hclDiags hcl.Diagnostics
+	if strings.HasSuffix(file.name, ".json") {
+		f, hclDiags = hcljson.Parse([]byte(file.content), file.name)
+	} else {
+		f, hclDiags = hclsyntax.ParseConfig([]byte(file.content), file.name, hcl.Pos{Line: 1, Column: 1})
+	}
+	diags = diags.Append(hclDiags)
+	if f == nil || f.Body == nil {
+		return nil, diags
+	}
+
+	attrs, hclDiags := f.Body.JustAttributes()
+	diags = diags.Append(hclDiags)
+
+	iv := make(terraform.InputValues, len(attrs))
+	for name, attr := range attrs {
+		val, hclDiags := attr.Expr.Value(nil)
+		diags = diags.Append(hclDiags)
+		iv[name] = &terraform.InputValue{
+			Value:       val,
+			SourceType:  file.sourceType,
+			SourceRange: tfdiags.SourceRangeFromHCL(attr.Expr.Range()),
+		}
+	}
+
+	return iv, diags
+}
+
+// ctyValue converts the given Go value to a cty value of the given type. A Go
+// value other than a cty.Value is converted from its JSON encoding, so structs
+// can use `json` tags for the attribute names.
+func ctyValue(value interface{}, ty cty.Type) (cty.Value, error) {
+	val, ok := value.(cty.Value)
+	if !ok {
+		b, err := json.Marshal(value)
+		if err != nil {
+			return cty.NilVal, err
+		}
+		impliedType, err := ctyjson.ImpliedType(b)
+		if err != nil {
+			return cty.NilVal, err
+		}
+		if val, err = ctyjson.Unmarshal(b, impliedType); err != nil {
+			return cty.NilVal, err
+		}
+	}
+
+	return convert.Convert(val, ty)
+}
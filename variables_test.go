@@ -0,0 +1,256 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/configs"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const typedVariablesCode = `
+variable "subnets" {
+  type = list(string)
+}
+
+variable "tags" {
+  type = map(string)
+}
+
+variable "replicas" {
+  type = number
+}
+
+output "subnets" {
+  value = join(",", var.subnets)
+}
+
+output "tags" {
+  value = join(",", [for k, v in var.tags : "${k}=${v}"])
+}
+
+output "replicas" {
+  value = var.replicas * 2
+}
+`
+
+func TestPlatform_variables(t *testing.T) {
+	type server struct {
+		Name  string `json:"name"`
+		Ports []int  `json:"ports"`
+	}
+
+	tests := []struct {
+		name    string
+		value   interface{}
+		ty      cty.Type
+		want    cty.Value
+		wantErr string
+	}{
+		{"string", "foo", cty.String, cty.StringVal("foo"), ""},
+		{"number to string", 3, cty.String, cty.StringVal("3"), ""},
+		{"number", 3, cty.Number, cty.NumberIntVal(3), ""},
+		{"string to number", "3.5", cty.Number, cty.NumberFloatVal(3.5), ""},
+		{"bool", true, cty.Bool, cty.True, ""},
+		{"list", []string{"a", "b"}, cty.List(cty.String), cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}), ""},
+		{"empty list", []string{}, cty.List(cty.String), cty.ListValEmpty(cty.String), ""},
+		{"map", map[string]string{"env": "dev"}, cty.Map(cty.String), cty.MapVal(map[string]cty.Value{"env": cty.StringVal("dev")}), ""},
+		{"object", server{Name: "web", Ports: []int{80}}, cty.Object(map[string]cty.Type{"name": cty.String, "ports": cty.List(cty.Number)}), cty.ObjectVal(map[string]cty.Value{
+			"name":  cty.StringVal("web"),
+			"ports": cty.ListVal([]cty.Value{cty.NumberIntVal(80)}),
+		}), ""},
+		{"untyped", map[string]interface{}{"a": 1}, cty.DynamicPseudoType, cty.ObjectVal(map[string]cty.Value{"a": cty.NumberIntVal(1)}), ""},
+		{"cty value", cty.StringVal("1"), cty.Number, cty.NumberIntVal(1), ""},
+		{"null", nil, cty.String, cty.NullVal(cty.String), ""},
+		{"not a list", "foo", cty.List(cty.String), cty.NilVal, `invalid value for variable "foo" of type list(string). list of string required`},
+		{"not a number", "foo", cty.Number, cty.NilVal, `invalid value for variable "foo" of type number. a number is required`},
+		{"invalid element", map[string]interface{}{"a": []int{1}}, cty.Map(cty.String), cty.NilVal, `invalid value for variable "foo" of type map(string). element "a": string required`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPlatform("").Var("foo", tt.value)
+			iv, err := p.variables(map[string]*configs.Variable{"foo": {Name: "foo", Type: tt.ty}})
+			if len(tt.wantErr) != 0 {
+				var typeErr *VariableTypeError
+				if !errors.As(err, &typeErr) || err.Error() != tt.wantErr {
+					t.Fatalf("Platform.variables() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Platform.variables() error = %v", err)
+			}
+			if got := iv["foo"].Value; !got.RawEquals(tt.want) {
+				t.Errorf("Platform.variables() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlatform_Apply_typedVariables(t *testing.T) {
+	p := NewPlatform(typedVariablesCode).BindVars(map[string]interface{}{
+		"subnets":  []string{"10.0.1.0/24", "10.0.2.0/24"},
+		"tags":     map[string]string{"env": "dev", "team": "infra"},
+		"replicas": 2,
+	})
+	if err := p.Apply(false); err != nil {
+		t.Fatalf("Platform.Apply() error = %v", err)
+	}
+
+	for name, want := range map[string]string{
+		"subnets":  "10.0.1.0/24,10.0.2.0/24",
+		"tags":     "env=dev,team=infra",
+		"replicas": "4",
+	} {
+		if got, err := p.OutputValueAsString(name); err != nil || got != want {
+			t.Errorf("Platform.OutputValueAsString(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+
+	err := p.Var("subnets", "10.0.1.0/24").Apply(false)
+	if err == nil || !strings.Contains(err.Error(), `variable "subnets" of type list(string)`) {
+		t.Errorf("Platform.Apply() error = %v, want an invalid type error", err)
+	}
+}
+
+func TestPlatform_VarFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", ".terranova_varfile_test")
+	if err != nil {
+		t.Fatalf("Failed to create temporal directory. %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	filename := filepath.Join(tmpDir, "dev.tfvars")
+	if err := ioutil.WriteFile(filename, []byte("d = \"dev.tfvars\"\ne = \"dev.tfvars\"\nf = \"dev.tfvars\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to create the variables file. %s", err)
+	}
+
+	p := NewPlatform("").
+		AddFile("terraform.tfvars", "a = \"terraform.tfvars\"\nb = \"terraform.tfvars\"\nc = \"terraform.tfvars\"\n").
+		AddFile("terraform.tfvars.json", `{"b": "terraform.tfvars.json", "c": "terraform.tfvars.json"}`).
+		AddFile("b.auto.tfvars.json", `{"c": "b.auto.tfvars.json"}`).
+		AddFile("a.auto.tfvars", "c = \"a.auto.tfvars\"\nd = \"a.auto.tfvars\"\n").
+		AddFile(filepath.Join("modules", "c.auto.tfvars"), "d = \"modules\"\n")
+	if _, err := p.VarFile(filename); err != nil {
+		t.Fatalf("Platform.VarFile() error = %v", err)
+	}
+	p.VarFileContent("prod.tfvars.json", `{"e": "prod.tfvars.json", "f": "prod.tfvars.json"}`).Var("f", "var")
+
+	declared := map[string]*configs.Variable{}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		declared[name] = &configs.Variable{Name: name, Type: cty.String}
+	}
+	iv, err := p.variables(declared)
+	if err != nil {
+		t.Fatalf("Platform.variables() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		want       string
+		sourceType terraform.ValueSourceType
+	}{
+		{"a", "terraform.tfvars", terraform.ValueFromAutoFile},
+		{"b", "terraform.tfvars.json", terraform.ValueFromAutoFile},
+		{"c", "b.auto.tfvars.json", terraform.ValueFromAutoFile},
+		{"d", "dev.tfvars", terraform.ValueFromNamedFile},
+		{"e", "prod.tfvars.json", terraform.ValueFromNamedFile},
+		{"f", "var", terraform.ValueFromCaller},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := iv[tt.name]
+			if got == nil || !got.Value.RawEquals(cty.StringVal(tt.want)) || got.SourceType != tt.sourceType {
+				t.Errorf("Platform.variables()[%q] = %#v, want %q from %s", tt.name, got, tt.want, tt.sourceType)
+			}
+		})
+	}
+
+	if _, err := NewPlatform("").VarFile(filepath.Join(tmpDir, "missing.tfvars")); !os.IsNotExist(err) {
+		t.Errorf("Platform.VarFile() of a missing file error = %v, want a not exist error", err)
+	}
+}
+
+func TestPlatform_VarFile_errors(t *testing.T) {
+	var (
+		diagsErr *DiagnosticsError
+		typeErr  *VariableTypeError
+	)
+
+	tests := []struct {
+		name        string
+		content     string
+		target      interface{}
+		wantWarning string
+	}{
+		{"invalid syntax", "subnets = [\n", &diagsErr, ""},
+		{"invalid json", `{"subnets": }`, &diagsErr, ""},
+		{"variable block", "variable \"subnets\" {}\n", &diagsErr, ""},
+		{"invalid type", "subnets = \"10.0.1.0/24\"\n", &typeErr, ""},
+		{"undeclared variable", "subnets = []\ntags = {}\nreplicas = 1\nzones = []\n", nil, "Value for undeclared variable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := "test.tfvars"
+			if strings.HasPrefix(tt.content, "{") {
+				name += ".json"
+			}
+			p := NewPlatform(typedVariablesCode).VarFileContent(name, tt.content)
+
+			_, err := p.Plan(false)
+			if tt.target == nil && err != nil {
+				t.Fatalf("Platform.Plan() error = %v", err)
+			}
+			if tt.target != nil && !errors.As(err, tt.target) {
+				t.Fatalf("Platform.Plan() error = %v, want %T", err, tt.target)
+			}
+			if len(tt.wantWarning) == 0 {
+				return
+			}
+			warnings := p.Warnings()
+			if len(warnings) != 1 || warnings[0].Summary != tt.wantWarning || warnings[0].Subject == nil || warnings[0].Subject.Filename != name {
+				t.Errorf("Platform.Warnings() = %v, want %q in %s", warnings, tt.wantWarning, name)
+			}
+		})
+	}
+}
+
+func TestPlatform_Apply_varFiles(t *testing.T) {
+	p := NewPlatform(typedVariablesCode).
+		AddFile("terraform.tfvars", "subnets = [\"10.0.1.0/24\"]\ntags = { env = \"dev\" }\nreplicas = 1\n").
+		VarFileContent("prod.tfvars.json", `{"tags": {"env": "prod", "team": "infra"}, "replicas": 3}`)
+	if err := p.Apply(false); err != nil {
+		t.Fatalf("Platform.Apply() error = %v", err)
+	}
+
+	for name, want := range map[string]string{
+		"subnets":  "10.0.1.0/24",
+		"tags":     "env=prod,team=infra",
+		"replicas": "6",
+	} {
+		if got, err := p.OutputValueAsString(name); err != nil || got != want {
+			t.Errorf("Platform.OutputValueAsString(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"github.com/hashicorp/terraform/tfdiags"
+)
+
+// Warnings returns the warnings found by the last operation, such as
+// deprecations reported by Terraform or the providers
+func (p *Platform) Warnings() Diagnostics {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append(Diagnostics(nil), p.warnings...)
+}
+
+// OnWarning sets a function to be called with every new warning found while
+// executing an operation
+func (p *Platform) OnWarning(fn func(Diagnostic)) *Platform {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.warningFn = fn
+	return p
+}
+
+// resetWarnings removes the warnings of the previous operation
+func (p *Platform) resetWarnings() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.warnings = nil
+}
+
+// addWarnings keeps the warnings in the given Terraform diagnostics, the
+// filenames are made relative to the given code directory
+func (p *Platform) addWarnings(diags tfdiags.Diagnostics, codeDir string) {
+	p.addWarningDiagnostics(newDiagnostics(diags, codeDir))
+}
+
+// addWarningDiagnostics keeps the warnings in the given diagnostics that were
+// not found before in the same operation
+func (p *Platform) addWarningDiagnostics(diags Diagnostics) {
+	var added Diagnostics
+
+	p.mu.Lock()
+	for _, d := range diags {
+		if d.Severity != SeverityWarning || p.hasWarning(d) {
+			continue
+		}
+		p.warnings = append(p.warnings, d)
+		added = append(added, d)
+	}
+	fn := p.warningFn
+	p.mu.Unlock()
+
+	if fn == nil {
+		return
+	}
+	for _, d := range added {
+		fn(d)
+	}
+}
+
+// hasWarning returns true if the warning was already found. The lock should be
+// hold by the caller.
+func (p *Platform) hasWarning(d Diagnostic) bool {
+	for _, w := range p.warnings {
+		if w.String() == d.String() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/providers"
+	"github.com/hashicorp/terraform/tfdiags"
+)
+
+func TestPlatform_Warnings(t *testing.T) {
+	mock := NewMockProvider(nil, "test", testSimpleSchema())
+	mock.ValidateResourceTypeConfigFn = func(req providers.ValidateResourceTypeConfigRequest) providers.ValidateResourceTypeConfigResponse {
+		var diags tfdiags.Diagnostics
+		diags = diags.Append(tfdiags.SimpleWarning("Deprecated resource"))
+		return providers.ValidateResourceTypeConfigResponse{Diagnostics: diags}
+	}
+	fields := platformFields{
+		Code: testSimpleInstance,
+		Providers: map[string]providers.Factory{
+			"test": providers.FactoryFixed(mock),
+		},
+	}
+
+	tests := []struct {
+		name string
+		opFn func(p *Platform) error
+	}{
+		{"plan", func(p *Platform) error {
+			_, err := p.Plan(false)
+			return err
+		}},
+		{"apply", func(p *Platform) error { return p.Apply(false) }},
+		{"validate", func(p *Platform) error { return p.Validate().Err() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var notified Diagnostics
+			p := newPlatformForTest(fields).OnWarning(func(d Diagnostic) {
+				notified = append(notified, d)
+			})
+
+			if err := tt.opFn(p); err != nil {
+				t.Fatalf("operation failed. %s", err)
+			}
+
+			got := p.Warnings()
+			if len(got) != 1 || got[0].Summary != "Deprecated resource" || got[0].Severity != SeverityWarning {
+				t.Errorf("Platform.Warnings() = %v, want 1 warning %q", got, "Deprecated resource")
+			}
+			if len(notified) != len(got) {
+				t.Errorf("Platform.OnWarning() notified %v, want %v", notified, got)
+			}
+
+			// A new operation resets the warnings
+			p.Code = map[string]string{"main.tf": nullDataSource}
+			if _, err := p.Plan(false); err != nil {
+				t.Fatalf("Platform.Plan() error = %v", err)
+			}
+			if got := p.Warnings(); len(got) != 0 {
+				t.Errorf("Platform.Warnings() = %v, want no warnings", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/hashicorp/terraform/states/statemgr"
+)
+
+// Workspace selects the workspace with the given name, the value of
+// `terraform.workspace` in the code. If the state is persisted to a state
+// backend, the workspace has to exist and its state is read from the backend.
+// Use NewWorkspace to create a workspace. Without a state backend, the platform
+// keeps the state of every workspace in memory, a workspace selected for the
+// first time has an empty state.
+func (p *Platform) Workspace(name string) (*Platform, error) {
+	if err := validateWorkspace(name); err != nil {
+		return p, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.backend == nil {
+		p.selectMemoryWorkspace(name)
+		return p, nil
+	}
+
+	exists, err := p.workspaceExists(name)
+	if err != nil {
+		return p, err
+	}
+	if !exists {
+		return p, fmt.Errorf("%w: %q", ErrWorkspaceNotFound, name)
+	}
+
+	sf, err := p.backend.ReadState(name)
+	if err != nil {
+		return p, err
+	}
+	if isEncryptedStateFile(sf) {
+		return p, ErrStateEncrypted
+	}
+	if sf == nil {
+		sf = statefile.New(states.NewState(), statemgr.NewLineage(), 0)
+	}
+	p.setStateFile(sf)
+	p.workspace = name
+
+	return p, nil
+}
+
+// CurrentWorkspace returns the name of the selected workspace
+func (p *Platform) CurrentWorkspace() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.workspace
+}
+
+// Workspaces returns the workspaces in the state backend, the default
+// workspace first
+func (p *Platform) Workspaces() ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.backend == nil {
+		return nil, ErrNoStateBackend
+	}
+	return p.backend.Workspaces()
+}
+
+// NewWorkspace creates a workspace with an empty state in the state backend
+// and selects it
+func (p *Platform) NewWorkspace(name string) (*Platform, error) {
+	if err := validateWorkspace(name); err != nil {
+		return p, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.backend == nil {
+		return p, ErrNoStateBackend
+	}
+	exists, err := p.workspaceExists(name)
+	if err != nil {
+		return p, err
+	}
+	if exists {
+		return p, fmt.Errorf("%w: %q", ErrWorkspaceExists, name)
+	}
+
+	sf := statefile.New(states.NewState(), statemgr.NewLineage(), 0)
+	if err := p.backend.WriteState(name, sf); err != nil {
+		return p, err
+	}
+	p.setStateFile(sf)
+	p.workspace = name
+
+	return p, nil
+}
+
+// DeleteWorkspace deletes the workspace with the given name from the state
+// backend. The selected workspace and the default workspace cannot be deleted.
+// A workspace with resources or outputs in its state is only deleted if force
+// is true, the resources won't be managed anymore.
+func (p *Platform) DeleteWorkspace(name string, force bool) error {
+	if err := validateWorkspace(name); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.backend == nil {
+		return ErrNoStateBackend
+	}
+	if name == p.workspace {
+		return fmt.Errorf("%w: %q", ErrCurrentWorkspace, name)
+	}
+	exists, err := p.workspaceExists(name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: %q", ErrWorkspaceNotFound, name)
+	}
+
+	if !force {
+		sf, err := p.backend.ReadState(name)
+		if err != nil {
+			return err
+		}
+		if sf != nil && sf.State != nil && !sf.State.Empty() {
+			return fmt.Errorf("%w: %q", ErrWorkspaceNotEmpty, name)
+		}
+	}
+
+	return p.backend.DeleteWorkspace(name)
+}
+
+// selectMemoryWorkspace keeps the state of the selected workspace in memory and
+// selects the workspace with the given name, with the state kept in memory or
+// an empty state. The platform lock has to be held by the caller.
+func (p *Platform) selectMemoryWorkspace(name string) {
+	if name == p.workspace {
+		return
+	}
+	if p.workspaceStates == nil {
+		p.workspaceStates = map[string]*statefile.File{}
+	}
+	p.workspaceStates[p.workspace] = p.stateFile(p.State)
+
+	sf, ok := p.workspaceStates[name]
+	if !ok {
+		sf = statefile.New(states.NewState(), statemgr.NewLineage(), 0)
+	}
+	delete(p.workspaceStates, name)
+	p.setStateFile(sf)
+	p.workspace = name
+}
+
+// workspaceExists returns true if the workspace is in the state backend. The
+// platform lock has to be held by the caller.
+func (p *Platform) workspaceExists(name string) (bool, error) {
+	workspaces, err := p.backend.Workspaces()
+	if err != nil {
+		return false, err
+	}
+	for _, workspace := range workspaces {
+		if workspace == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// validateWorkspace returns an error if the workspace name is not valid, it
+// has to be safe to use in a URL path or a filename
+func validateWorkspace(name string) error {
+	if len(name) == 0 || url.PathEscape(name) != name || name == "." || name == ".." {
+		return fmt.Errorf("%w: %q", ErrInvalidWorkspace, name)
+	}
+	return nil
+}
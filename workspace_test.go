@@ -0,0 +1,168 @@
+/*
+Copyright The Terranova Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terranova
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const workspaceCode = `
+output "workspace" {
+  value = terraform.workspace
+}
+`
+
+func TestPlatform_Workspace(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", ".terranova_workspace_test")
+	if err != nil {
+		t.Fatalf("Failed to create temporal directory. %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	filename := filepath.Join(tmpDir, "test.tfstate")
+
+	p, err := NewPlatform(workspaceCode).PersistStateToFile(filename)
+	if err != nil {
+		t.Fatalf("Platform.PersistStateToFile() error = %v", err)
+	}
+	assertWorkspaceOutput(t, p, DefaultWorkspace)
+
+	// Create and select workspaces
+	if _, err := p.NewWorkspace("staging"); err != nil {
+		t.Fatalf("Platform.NewWorkspace() error = %v", err)
+	}
+	if got := p.CurrentWorkspace(); got != "staging" {
+		t.Errorf("Platform.CurrentWorkspace() = %q, want staging", got)
+	}
+	if _, err := p.OutputValueAsString("workspace"); err == nil {
+		t.Errorf("Platform.NewWorkspace() expected an empty state")
+	}
+	assertWorkspaceOutput(t, p, "staging")
+	if _, err := os.Stat(filepath.Join(tmpDir, "terraform.tfstate.d", "staging", "test.tfstate")); err != nil {
+		t.Errorf("the state of the workspace was not saved. %s", err)
+	}
+
+	if _, err := p.NewWorkspace("dev"); err != nil {
+		t.Fatalf("Platform.NewWorkspace() error = %v", err)
+	}
+	if got, err := p.Workspaces(); err != nil || !reflect.DeepEqual(got, []string{DefaultWorkspace, "dev", "staging"}) {
+		t.Errorf("Platform.Workspaces() = %v, %v, want [default dev staging]", got, err)
+	}
+
+	for _, workspace := range []string{DefaultWorkspace, "staging"} {
+		if _, err := p.Workspace(workspace); err != nil {
+			t.Fatalf("Platform.Workspace() error = %v", err)
+		}
+		if got, err := p.OutputValueAsString("workspace"); err != nil || got != workspace {
+			t.Errorf("Platform.Workspace() output = %q, %v, want %q", got, err, workspace)
+		}
+	}
+
+	// A plan is only applied to the workspace it was created for
+	plan, err := p.Plan(false)
+	if err != nil {
+		t.Fatalf("Platform.Plan() error = %v", err)
+	}
+	if _, err := p.Workspace("dev"); err != nil {
+		t.Fatalf("Platform.Workspace() error = %v", err)
+	}
+	if err := p.ApplyPlan(plan); !errors.Is(err, ErrStalePlan) {
+		t.Errorf("Platform.ApplyPlan() error = %v, want %v", err, ErrStalePlan)
+	}
+
+	// Errors
+	tests := []struct {
+		name    string
+		errFn   func() error
+		wantErr error
+	}{
+		{"select missing", func() error { _, err := p.Workspace("prod"); return err }, ErrWorkspaceNotFound},
+		{"select invalid", func() error { _, err := p.Workspace("a/b"); return err }, ErrInvalidWorkspace},
+		{"create existing", func() error { _, err := p.NewWorkspace("staging"); return err }, ErrWorkspaceExists},
+		{"delete current", func() error { return p.DeleteWorkspace("dev", false) }, ErrCurrentWorkspace},
+		{"delete default", func() error { return p.DeleteWorkspace(DefaultWorkspace, true) }, ErrDefaultWorkspace},
+		{"delete missing", func() error { return p.DeleteWorkspace("prod", false) }, ErrWorkspaceNotFound},
+		{"delete not empty", func() error { return p.DeleteWorkspace("staging", false) }, ErrWorkspaceNotEmpty},
+		{"no backend", func() error { _, err := NewPlatform("").Workspaces(); return err }, ErrNoStateBackend},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.errFn(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Delete workspaces
+	if _, err := p.Workspace(DefaultWorkspace); err != nil {
+		t.Fatalf("Platform.Workspace() error = %v", err)
+	}
+	if err := p.DeleteWorkspace("dev", false); err != nil {
+		t.Errorf("Platform.DeleteWorkspace() error = %v", err)
+	}
+	if err := p.DeleteWorkspace("staging", true); err != nil {
+		t.Errorf("Platform.DeleteWorkspace() error = %v", err)
+	}
+	if got, err := p.Workspaces(); err != nil || !reflect.DeepEqual(got, []string{DefaultWorkspace}) {
+		t.Errorf("Platform.Workspaces() = %v, %v, want [default]", got, err)
+	}
+
+	// Without a state backend, the state of every workspace is kept in memory
+	p, err = NewPlatform(workspaceCode).Workspace("prod")
+	if err != nil {
+		t.Fatalf("Platform.Workspace() error = %v", err)
+	}
+	assertWorkspaceOutput(t, p, "prod")
+
+	p = newPlatformForTest(testsPlatformsFields["test instance"])
+	if _, err := p.Workspace("staging"); err != nil {
+		t.Fatalf("Platform.Workspace() error = %v", err)
+	}
+	if err := p.Apply(false); err != nil {
+		t.Fatalf("Platform.Apply() error = %v", err)
+	}
+	for _, tt := range []struct {
+		workspace string
+		want      []string
+	}{
+		{"prod", []string{}},
+		{"staging", []string{"test_instance.foo"}},
+		{DefaultWorkspace, []string{}},
+		{"staging", []string{"test_instance.foo"}},
+	} {
+		if _, err := p.Workspace(tt.workspace); err != nil {
+			t.Fatalf("Platform.Workspace(%q) error = %v", tt.workspace, err)
+		}
+		if got := p.StateList(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Platform.StateList() in %q = %v, want %v", tt.workspace, got, tt.want)
+		}
+	}
+}
+
+func assertWorkspaceOutput(t *testing.T, p *Platform, want string) {
+	t.Helper()
+	if err := p.Apply(false); err != nil {
+		t.Fatalf("Platform.Apply() error = %v", err)
+	}
+	if got, err := p.OutputValueAsString("workspace"); err != nil || got != want {
+		t.Errorf("terraform.workspace = %q, %v, want %q", got, err, want)
+	}
+}